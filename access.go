@@ -0,0 +1,163 @@
+package badge
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/google/go-github/v37/github"
+)
+
+// Private repo access params and headers.
+const (
+	paramAPIKey  = "key"
+	headerAPIKey = "X-AB-Key"
+)
+
+// allowedNets are the client networks allowed to read private repo badges.
+var allowedNets []*net.IPNet
+
+// requestAccess reports whether a request may read badges of the private
+// repos of an owner, and whether that follows from the query string alone,
+// which makes responses safe to cache per query.
+func requestAccess(r *http.Request, signed bool, host, owner string) (granted, viaQuery bool) {
+	if !enabledFeatures[featurePrivate] {
+		return false, false
+	}
+	if signed || validAPIKey(r.URL.Query().Get(paramAPIKey), host, owner) {
+		return true, true
+	}
+	if validAPIKey(r.Header.Get(headerAPIKey), host, owner) {
+		return true, false
+	}
+	if ip := clientIP(r); ip != nil && containsIP(allowedNets, ip) {
+		return true, false
+	}
+	return false, false
+}
+
+// requireAPIKey fails the request unless it carries an API key valid for
+// an owner, either in the X-AB-Key header or as a bearer token.
+// An empty owner requires a key that is not limited to owners.
+func requireAPIKey(w http.ResponseWriter, r *http.Request, host, owner string) bool {
+	key := r.Header.Get(headerAPIKey)
+	if key == "" {
+		key = strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	}
+	if !validAPIKey(key, host, owner) {
+		http.Error(w, "Invalid API key", http.StatusUnauthorized)
+		return false
+	}
+	return true
+}
+
+// validAPIKey checks a key against the configured key hashes.
+// Keys limited to an owner are only valid for that owner.
+func validAPIKey(key, host, owner string) bool {
+	if key == "" {
+		return false
+	}
+	sum := sha256.Sum256([]byte(key))
+	hash := hex.EncodeToString(sum[:])
+	valid := false
+	for _, entry := range config.APIKeyHashes {
+		scope, allowed := splitAPIKeyHash(entry)
+		if subtle.ConstantTimeCompare([]byte(hash), []byte(strings.ToLower(allowed))) == 1 &&
+			(scope == "" || owner != "" && strings.EqualFold(scope, ownerScope(host, owner))) {
+			valid = true
+		}
+	}
+	return valid
+}
+
+// splitAPIKeyHash splits an API key hash entry of the form hash, owner=hash
+// or host/owner=hash into the owner it is limited to and the hash.
+func splitAPIKeyHash(entry string) (scope, hash string) {
+	eq := strings.LastIndex(entry, "=")
+	if eq < 0 {
+		return "", entry
+	}
+	return entry[:eq], entry[eq+1:]
+}
+
+// validKeyScope reports whether the owner of an API key hash entry is
+// empty, an owner, or an owner prefixed with a host.
+func validKeyScope(scope string) bool {
+	if scope == "" {
+		return true
+	}
+	parts := strings.Split(scope, "/")
+	if len(parts) == 2 && !validHostName(parts[0]) {
+		return false
+	}
+	owner := parts[len(parts)-1]
+	return len(parts) <= 2 && owner != "" && !strings.ContainsAny(owner, " =")
+}
+
+// ownerScope names an owner in API key hash entries.
+func ownerScope(host, owner string) string {
+	if host == "" {
+		return owner
+	}
+	return host + "/" + owner
+}
+
+// clientIP returns the address of the client,
+// as reported by trusted proxies in between.
+func clientIP(r *http.Request) net.IP {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return nil
+	}
+	return forwardedClientIP(ip, r.Header)
+}
+
+// parseNets parses a list of CIDR ranges or single addresses.
+func parseNets(list []string) ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, 0, len(list))
+	for _, item := range list {
+		if !strings.Contains(item, "/") {
+			ip := net.ParseIP(item)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid address %q", item)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(item)
+		if err != nil {
+			return nil, err
+		}
+		nets = append(nets, network)
+	}
+	return nets, nil
+}
+
+// isPrivateRepo reports whether a repo is private, shared across concurrent badges.
+func isPrivateRepo(ctx context.Context, client *github.Client, owner, repo string) (bool, error) {
+	key := strings.Join([]string{"private", client.BaseURL.Host, owner, repo}, "\x00")
+	val, err := lookups.do(ctx, key, func(ctx context.Context) (interface{}, error) {
+		info, _, err := client.Repositories.Get(ctx, owner, repo)
+		if err != nil {
+			return nil, err
+		}
+		return info.GetPrivate(), nil
+	})
+	if err != nil {
+		return false, err
+	}
+	return val.(bool), nil
+}
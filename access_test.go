@@ -0,0 +1,111 @@
+package badge
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func keyHash(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestValidAPIKeyScopes(t *testing.T) {
+	defer func(cfg *Config) { config = cfg }(config)
+	config = &Config{APIKeyHashes: []string{
+		keyHash("admin"),
+		"acme=" + keyHash("acme"),
+		"ghe.example.com/corp=" + keyHash("corp"),
+	}}
+	tests := []struct {
+		key   string
+		host  string
+		owner string
+		want  bool
+	}{
+		{"admin", "", "", true},
+		{"admin", "", "acme", true},
+		{"admin", "ghe.example.com", "corp", true},
+		{"acme", "", "acme", true},
+		{"acme", "", "ACME", true},
+		{"acme", "", "other", false},
+		{"acme", "", "", false},
+		{"acme", "ghe.example.com", "acme", false},
+		{"corp", "ghe.example.com", "corp", true},
+		{"corp", "", "corp", false},
+		{"wrong", "", "acme", false},
+		{"", "", "acme", false},
+	}
+	for _, tt := range tests {
+		if got := validAPIKey(tt.key, tt.host, tt.owner); got != tt.want {
+			t.Errorf("%s for %s/%s: got %v, want %v", tt.key, tt.host, tt.owner, got, tt.want)
+		}
+	}
+}
+
+func TestRequireAPIKeyScoped(t *testing.T) {
+	defer func(cfg *Config) { config = cfg }(config)
+	config = &Config{APIKeyHashes: []string{"acme=" + keyHash("acme")}}
+	for _, tt := range []struct {
+		owner string
+		want  int
+	}{{"acme", http.StatusOK}, {"other", http.StatusUnauthorized}, {"", http.StatusUnauthorized}} {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set("Authorization", "Bearer acme")
+		w := httptest.NewRecorder()
+		requireAPIKey(w, r, "", tt.owner)
+		if w.Code != tt.want {
+			t.Errorf("%q: got %d, want %d", tt.owner, w.Code, tt.want)
+		}
+	}
+}
+
+func TestValidKeyScope(t *testing.T) {
+	tests := []struct {
+		scope string
+		want  bool
+	}{
+		{"", true},
+		{"acme", true},
+		{"ghe.example.com/acme", true},
+		{"ghe.example.com/", false},
+		{"/acme", false},
+		{"a/b/c", false},
+		{"bad host/acme", false},
+	}
+	for _, tt := range tests {
+		if got := validKeyScope(tt.scope); got != tt.want {
+			t.Errorf("%q: got %v, want %v", tt.scope, got, tt.want)
+		}
+	}
+}
+
+func TestOnboardKeyScope(t *testing.T) {
+	setupOnce.Do(func() {})
+	defer func(cfg *Config, features map[string]bool) {
+		config, enabledFeatures = cfg, features
+	}(config, enabledFeatures)
+	config = &Config{APIKeyHashes: []string{"acme=" + keyHash("acme")}}
+	enabledFeatures = map[string]bool{featureOnboard: true}
+	tests := []struct {
+		org  string
+		want int
+	}{
+		{"other", http.StatusUnauthorized},
+		{"ghe.example.com/acme", http.StatusBadRequest},
+		{"a/b/c", http.StatusBadRequest},
+		{"", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "/?org="+tt.org, nil)
+		r.Header.Set(headerAPIKey, "acme")
+		w := httptest.NewRecorder()
+		OnboardHTTP(w, r)
+		if w.Code != tt.want {
+			t.Errorf("%q: got %d %q, want %d", tt.org, w.Code, w.Body.String(), tt.want)
+		}
+	}
+}
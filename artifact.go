@@ -0,0 +1,233 @@
+package badge
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/go-github/v37/github"
+)
+
+// artifactTimeout bounds the time spent downloading and reading an artifact.
+const artifactTimeout = 15 * time.Second
+
+// maxArtifactSize caps the downloaded artifact archive.
+// Archives of the v4 artifact backend carry more overhead than v3 ones.
+const maxArtifactSize = 64 * 1024
+
+// maxArtifactText caps the text of an artifact file split into lines.
+const maxArtifactText = 1024
+
+// maxReportText caps the text read from an artifact file,
+// leaving room for structured reports handed to a parser.
+const maxReportText = 256 * 1024
+
+// maxLineLen caps each line of artifact text, in bytes.
+const maxLineLen = 128
+
+// errArtifactTooLarge is returned for archives exceeding maxArtifactSize.
+var errArtifactTooLarge = errors.New("artifact too large")
+
+// errAmbiguousFile is returned for strict loads of artifacts with several files.
+var errAmbiguousFile = errors.New("artifact has several files")
+
+// bufferPool recycles download buffers across requests.
+var bufferPool = sync.Pool{
+	New: func() interface{} { return new(bytes.Buffer) },
+}
+
+func getBuffer() *bytes.Buffer {
+	return bufferPool.Get().(*bytes.Buffer)
+}
+
+// putBuffer returns a buffer to the pool, dropping ones that grew oversized.
+func putBuffer(buf *bytes.Buffer) {
+	if buf.Cap() > 2*maxArtifactSize {
+		return
+	}
+	buf.Reset()
+	bufferPool.Put(buf)
+}
+
+// readCapped reads all of rd into buf, failing if it exceeds limit bytes.
+func readCapped(buf *bytes.Buffer, rd io.Reader, limit int64) error {
+	n, err := buf.ReadFrom(io.LimitReader(rd, limit+1))
+	if err != nil {
+		return err
+	}
+	if n > limit {
+		return errArtifactTooLarge
+	}
+	return nil
+}
+
+// findArtifactID returns the ID of the first unexpired artifact with the given name.
+// Artifacts uploaded with upload-artifact@v4 are immutable and unique per run,
+// older ones may repeat a name.
+func findArtifactID(artifacts []*github.Artifact, name string) int64 {
+	for _, artifact := range artifacts {
+		if artifact.GetName() == name && !artifact.GetExpired() {
+			return artifact.GetID()
+		}
+	}
+	return 0
+}
+
+// countArtifacts returns the number of unexpired artifacts with the given name.
+func countArtifacts(artifacts []*github.Artifact, name string) int {
+	n := 0
+	for _, artifact := range artifacts {
+		if artifact.GetName() == name && !artifact.GetExpired() {
+			n++
+		}
+	}
+	return n
+}
+
+// parseArtifactRef parses an artifact given by numeric ID or by node ID.
+// Node IDs are the base64 encoded "<len>:Artifact<id>" form returned by the REST API.
+func parseArtifactRef(ref string) (int64, error) {
+	if id, err := strconv.ParseInt(ref, 10, 64); err == nil {
+		if id <= 0 {
+			return 0, errors.New("artifact ID must be positive")
+		}
+		return id, nil
+	}
+	decoded, err := base64.StdEncoding.DecodeString(ref)
+	if err != nil {
+		decoded, err = base64.RawStdEncoding.DecodeString(ref)
+	}
+	if err != nil {
+		return 0, errors.New("not an artifact ID or node ID")
+	}
+	parts := strings.SplitN(string(decoded), ":", 2)
+	if len(parts) != 2 || !strings.HasPrefix(parts[1], "Artifact") {
+		return 0, errors.New("node ID does not refer to an artifact")
+	}
+	id, err := strconv.ParseInt(strings.TrimPrefix(parts[1], "Artifact"), 10, 64)
+	if err != nil || id <= 0 {
+		return 0, errors.New("malformed artifact node ID")
+	}
+	return id, nil
+}
+
+// loadArtifact downloads an artifact and returns the text of its last file.
+// Strict loads fail with errAmbiguousFile if it has several files.
+// Only maxArtifactText bytes are read unless report reports, given those,
+// that the file is a report to be read up to maxReportText bytes.
+func loadArtifact(ctx context.Context, client *github.Client, owner, repo string, artifactID int64, strict bool, report func(head []byte) bool) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, artifactTimeout)
+	defer cancel()
+	// Resolve download URL. Both artifact backends redirect to
+	// pre-signed blob storage that must be fetched without GitHub auth.
+	downloadURL, _, err := client.Actions.DownloadArtifact(ctx, owner, repo, artifactID, false)
+	if err != nil {
+		return nil, err
+	}
+	// Submit download request.
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, downloadURL.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	res, err := downloadClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("status %s", res.Status)
+	}
+	// Read body.
+	zipBuf := getBuffer()
+	defer putBuffer(zipBuf)
+	if err := readCapped(zipBuf, &contextReader{ctx, res.Body}, maxArtifactSize); err != nil {
+		return nil, err
+	}
+	// Read ZIP header.
+	rd, err := zip.NewReader(bytes.NewReader(zipBuf.Bytes()), int64(zipBuf.Len()))
+	if err != nil {
+		return nil, err
+	}
+	// Find last file.
+	var zipFile *zip.File
+	for _, currentZipFile := range rd.File {
+		if currentZipFile.FileInfo().IsDir() {
+			continue
+		}
+		if strict && zipFile != nil {
+			return nil, errAmbiguousFile
+		}
+		zipFile = currentZipFile
+	}
+	if zipFile == nil {
+		return nil, nil
+	}
+	// Open file in ZIP.
+	stream, err := zipFile.Open()
+	if err != nil {
+		return nil, err
+	}
+	// Read file.
+	defer stream.Close()
+	text := &contextReader{ctx, stream}
+	buf := getBuffer()
+	defer putBuffer(buf)
+	if _, err := buf.ReadFrom(io.LimitReader(text, maxArtifactText)); err != nil {
+		return nil, err
+	}
+	if buf.Len() == maxArtifactText && report(buf.Bytes()) {
+		if _, err := buf.ReadFrom(io.LimitReader(text, maxReportText-maxArtifactText)); err != nil {
+			return nil, err
+		}
+	}
+	// The buffer goes back to the pool.
+	return append([]byte(nil), buf.Bytes()...), nil
+}
+
+// artifactLines returns the trimmed lines of artifact text.
+func artifactLines(text []byte) []string {
+	if len(text) > maxArtifactText {
+		text = text[:maxArtifactText]
+	}
+	lines := strings.Split(string(text), "\n")
+	for i, line := range lines {
+		lines[i] = truncateBytes(strings.TrimSpace(line), maxLineLen)
+	}
+	return lines
+}
+
+// contextReader fails reads once its context is done.
+type contextReader struct {
+	ctx context.Context
+	rd  io.Reader
+}
+
+func (r *contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.rd.Read(p)
+}
+
+// truncateBytes cuts s to at most max bytes without splitting a character.
+func truncateBytes(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	// Cut before the character starting at max unless it starts there.
+	// Invalid bytes earlier on are left for sanitizing.
+	for max > 0 && !utf8.RuneStart(s[max]) {
+		max--
+	}
+	return s[:max]
+}
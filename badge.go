@@ -0,0 +1,73 @@
+package badge
+
+// Length limits of rendered text, in characters.
+var (
+	maxSubjectLen = defaultMaxSubjectLen
+	maxStatusLen  = defaultMaxStatusLen
+)
+
+const (
+	defaultMaxSubjectLen = 64
+	defaultMaxStatusLen  = 128
+)
+
+// Badge is a GitHub Badge.
+type Badge struct {
+	Subject string
+	Status  string
+	Color   string
+	Label   string
+	List    string
+	Icon    string
+	Style   string
+	// Logo is an image data URI shown instead of Icon.
+	Logo string
+	// IconPos places the icon left of the subject, left of the status
+	// or instead of the subject text: "left", "right" or "only".
+	IconPos string
+	// Icon2 is a second icon shown left of the status.
+	Icon2 string
+	// Split is a second status shown right of Status, in SplitColor.
+	Split      string
+	SplitColor string
+	// Sparkline holds recent values, oldest first, drawn as a trend chart.
+	Sparkline []float64
+	// Running marks the status with an animated indicator.
+	Running bool
+	// Scale multiplies the rendered dimensions, 0 means 1.
+	Scale float64
+	// Palette names the palette of color names, the configured one if empty.
+	Palette string
+	// Alt replaces the text announced by screen readers, "Subject: Status" if empty.
+	Alt string
+	// MinWidth and MaxWidth bound the width of native badges in pixels,
+	// by widening the status or squeezing its text. 0 means no bound.
+	MinWidth int
+	MaxWidth int
+	// LabelWidth is the least width of the subject, to line up stacked badges.
+	LabelWidth int
+	// MaxLen shortens the status and split status further than maxStatusLen.
+	MaxLen int
+}
+
+// statusLen returns the length limit of the status, 0 if there is none.
+func (b *Badge) statusLen() int {
+	if b.MaxLen > 0 && (maxStatusLen <= 0 || b.MaxLen < maxStatusLen) {
+		return b.MaxLen
+	}
+	return maxStatusLen
+}
+
+// truncateMiddle shortens s to max characters by replacing its middle with an ellipsis.
+func truncateMiddle(s string, max int) string {
+	runes := []rune(s)
+	if max <= 0 || len(runes) <= max {
+		return s
+	}
+	if max == 1 {
+		return "…"
+	}
+	head := (max - 1) / 2
+	tail := max - 1 - head
+	return string(runes[:head]) + "…" + string(runes[len(runes)-tail:])
+}
@@ -2,253 +2,441 @@
 package badge
 
 import (
-	"archive/zip"
-	"bytes"
 	"context"
 	"fmt"
-	"io"
-	"io/ioutil"
 	"log"
 	"net/http"
-	"net/url"
-	"os"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	secretmanager "cloud.google.com/go/secretmanager/apiv1"
 	"github.com/bradleyfalzon/ghinstallation"
-	"github.com/google/go-github/v37/github"
 	secretmanagerpb "google.golang.org/genproto/googleapis/cloud/secretmanager/v1"
 )
 
-const (
-	envPrivateKeySecret = "AB_PRIVATE_KEY_SECRET_NAME"
-	envGHAppID          = "AB_GH_APP_ID"
+var (
+	setupOnce     sync.Once
+	config        *Config
+	appsTransport *ghinstallation.AppsTransport
+	signingKey    []byte
 )
 
-var appsTransport *ghinstallation.AppsTransport
+// lookups shares run and artifact lookups between badges of the same run.
+var lookups = newCoalescer(coalesceWindow)
 
-func init() {
-	privateKey := githubPrivateKey()
-	appID, err := strconv.ParseInt(os.Getenv(envGHAppID), 10, 64)
+// renders caches finished badge responses per query string.
+var renders = newRenderCache(renderCacheTTL)
+
+// setup loads the config and GitHub credentials on first use.
+func setup() {
+	setupOnce.Do(func() {
+		cfg, err := LoadConfig()
+		if err != nil {
+			log.Fatalf("Failed to load config: %s", err)
+		}
+		if err := cfg.Validate(); err != nil {
+			log.Fatalf("Invalid config: %s", err)
+		}
+		if err := applyConfig(cfg); err != nil {
+			log.Fatalf("Failed to apply config: %s", err)
+		}
+		for _, step := range credentialSteps(cfg) {
+			if err := step.run(); err != nil {
+				log.Fatalf("Failed to set up %s: %s", step.name, err)
+			}
+		}
+	})
+}
+
+// startupStep installs one set of credentials at startup.
+type startupStep struct {
+	name   string
+	detail string
+	run    func() error
+}
+
+// credentialSteps returns the steps fetching and installing the credentials
+// named in a config, shared by setup and Diagnose.
+func credentialSteps(cfg *Config) []startupStep {
+	return []startupStep{
+		{"private key", cfg.PrivateKeySecret, func() error {
+			privateKey, err := githubPrivateKey(cfg.PrivateKeySecret)
+			if err != nil {
+				return err
+			}
+			appsTransport, err = newGitHubTransport(cfg.AppID, privateKey)
+			return err
+		}},
+		{"ghes hosts", strings.Join(cfg.EnterpriseHosts, ", "), setupEnterpriseTransports},
+		{"sonar token", cfg.SonarTokenSecret, func() error {
+			if cfg.SonarTokenSecret == "" {
+				return nil
+			}
+			var err error
+			sonarToken, err = accessSecret(cfg.SonarTokenSecret)
+			return err
+		}},
+		{"signing key", cfg.SigningKeySecret, func() error {
+			if cfg.SigningKeySecret == "" {
+				return nil
+			}
+			var err error
+			signingKey, err = accessSecret(cfg.SigningKeySecret)
+			return err
+		}},
+	}
+}
+
+// applyConfig installs a validated config and everything derived from it,
+// except for credentials.
+func applyConfig(cfg *Config) error {
+	var err error
+	allowedNets, err = parseNets(cfg.AllowedIPs)
+	if err != nil {
+		return err
+	}
+	trustedProxies, err = parseNets(cfg.TrustedProxies)
+	if err != nil {
+		return err
+	}
+	redactions, err = compileRedactions(cfg.RedactDefaults, cfg.RedactPatterns)
 	if err != nil {
-		panic(err)
+		return err
 	}
-	appsTransport = newGitHubTransport(appID, privateKey)
+	enterpriseHosts, err = parseEnterpriseHosts(cfg.EnterpriseHosts)
+	if err != nil {
+		return err
+	}
+	maxSubjectLen, maxStatusLen = cfg.MaxSubjectLen, cfg.MaxStatusLen
+	unsafeTextMode = cfg.UnsafeText
+	defaultPalette = strings.ToLower(cfg.Palette)
+	emptyText, emptyColor = cfg.EmptyText, cfg.EmptyColor
+	livePollInterval = cfg.LiveInterval
+	configureUpstream(cfg)
+	renderer = renderers[cfg.Renderer](cfg.RendererURL)
+	if cfg.RendererProxy {
+		renderer = proxyRenderer{next: renderer}
+	}
+	enabledFeatures = parseFeatures(cfg.Features)
+	config = cfg
+	return nil
 }
 
 // GenBadgeHTTP is a HTTP cloud function that returns a badge.
 func GenBadgeHTTP(w http.ResponseWriter, r *http.Request) {
+	serveBadge(w, r, r.URL.RawQuery)
+}
+
+// serveBadge serves the badge of a request, whose cached render is
+// stored under cacheKey. Endpoints rewriting params into those of
+// GenBadgeHTTP pass keys that can't collide with its own queries.
+func serveBadge(w http.ResponseWriter, r *http.Request, cacheKey string) {
+	setup()
+	timing := newServerTiming()
+	// Check share link signature.
+	signed, err := verifySignedLink(r, time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	format := negotiateFormat(w, r)
+	if format != r.FormValue("format") {
+		cacheKey += "\x00" + format
+	}
+	// Serve cache hits before decoding anything else. Maintenance
+	// bypasses the cache, so that it replaces cached badges right away.
+	cacheable := r.Method == http.MethodGet && config.Maintenance == ""
+	if cacheable {
+		if hit := renders.get(cacheKey); hit != nil {
+			timing.add("cache", "hit", time.Since(timing.start))
+			timing.setHeaders(w.Header())
+			hit.writeTo(w)
+			return
+		}
+		timing.add("cache", "miss", -1)
+	}
 	ctx := r.Context()
-	// Decode params.
-	repoParam := r.FormValue("repo")
-	if repoParam == "" {
-		http.Error(w, "Missing repo key", http.StatusBadRequest)
+	if !validFormat(format) {
+		http.Error(w, "Unknown format", http.StatusBadRequest)
 		return
 	}
-	repoParts := strings.SplitN(repoParam, "/", 2)
-	if len(repoParts) != 2 {
-		http.Error(w, "Invalid repo key", http.StatusBadRequest)
+	style := r.FormValue("style")
+	if !validStyle(style) {
+		http.Error(w, "Unknown style", http.StatusBadRequest)
 		return
 	}
-	owner := repoParts[0]
-	repo := repoParts[1]
-	branch := r.FormValue("branch")
-	if branch == "" {
-		http.Error(w, "Missing branch key", http.StatusBadRequest)
+	// look holds the rendering options shared by all badges of the request.
+	look := Badge{Style: style, Palette: r.FormValue("palette"), Alt: r.FormValue("alt")}
+	if !validPalette(look.Palette) {
+		http.Error(w, "Unknown palette", http.StatusBadRequest)
 		return
 	}
-	runName := r.FormValue("run")
-	if runName == "" {
-		http.Error(w, "Missing run key", http.StatusBadRequest)
+	if !validIconPos(r.FormValue("iconPos")) {
+		http.Error(w, "Unknown iconPos", http.StatusBadRequest)
 		return
 	}
-	badgeName := r.FormValue("badge")
-	if badgeName == "" {
-		http.Error(w, "Missing badge key", http.StatusBadRequest)
+	look.Scale, err = parseScale(r, style)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	for _, param := range []struct {
+		key   string
+		width *int
+	}{{"minWidth", &look.MinWidth}, {"maxWidth", &look.MaxWidth}, {"labelWidth", &look.LabelWidth}} {
+		if *param.width, err = widthParam(r, param.key); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	if param := r.FormValue("maxlen"); param != "" {
+		look.MaxLen, err = strconv.Atoi(param)
+		if err != nil || look.MaxLen <= 0 {
+			http.Error(w, "Invalid maxlen", http.StatusBadRequest)
+			return
+		}
+	}
 	subject := r.FormValue("subject")
-	if subject == "" {
-		http.Error(w, "Missing subject key", http.StatusBadRequest)
+	fallback := r.FormValue("fallback")
+	fallbackColor := r.FormValue("fallbackColor")
+	if fallbackColor == "" {
+		fallbackColor = "grey"
+	}
+	// failWith reports an error, or renders text instead if not empty.
+	failWith := func(msg, text string) {
+		if text == "" || subject == "" {
+			http.Error(w, msg, http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("X-AB-Error", msg)
+		w.Header().Set("Cache-Control", "no-cache")
+		badge := look
+		badge.Subject, badge.Status, badge.Color = subject, text, fallbackColor
+		badge.Alt = ""
+		writeBadge(ctx, w, &badge, format, timing)
+	}
+	// fail reports an error, or renders the fallback text if one was given.
+	fail := func(msg string) {
+		failWith(msg, fallback)
+	}
+	// Decode params.
+	query, err := parseBadgeQuery(r)
+	if err != nil {
+		fail(err.Error())
 		return
 	}
-	// Get installation ID.
-	appClient := github.NewClient(&http.Client{Transport: appsTransport})
-	installation, _, err := appClient.Apps.FindRepositoryInstallation(ctx, owner, repo)
-	if err != nil || installation == nil {
-		http.Error(w, "Can't find installation for repo", http.StatusBadRequest)
+	subjectFrom := r.FormValue("subjectFrom")
+	if subject == "" && subjectFrom == "" {
+		fail("Missing subject key")
 		return
 	}
-	// Create repo client.
-	repoTransport := ghinstallation.NewFromAppsTransport(appsTransport, installation.GetID())
-	repoClient := github.NewClient(&http.Client{Transport: repoTransport})
-	// List runs in repo.
-	runs, _, err := repoClient.Actions.ListRepositoryWorkflowRuns(ctx, owner, repo, &github.ListWorkflowRunsOptions{
-		Branch: branch,
-		Event:  "push",
-		Status: "success",
-	})
-	if err != nil {
-		http.Error(w, "Failed to list runs", http.StatusBadRequest)
+	// Announce maintenance instead of resolving.
+	if text := maintenanceText(query); text != "" {
+		w.Header().Set("X-AB-Maintenance", "1")
+		w.Header().Set("Cache-Control", "no-cache")
+		badge := look
+		badge.Subject, badge.Status, badge.Color = subject, text, "orange"
+		badge.Alt = ""
+		if badge.Subject == "" {
+			badge.Subject = "status"
+		}
+		writeBadge(ctx, w, &badge, format, timing)
 		return
 	}
-	// Find run matching run name.
-	var runID int64
-	for _, run := range runs.WorkflowRuns {
-		if strings.ToLower(run.GetName()) == strings.ToLower(runName) {
-			runID = run.GetID()
-			break
+	var grantedByQuery bool
+	query.allowPrivate, grantedByQuery = requestAccess(r, signed, query.Host, query.Owner)
+	// Resolve subject from second source in parallel.
+	lookupStart := time.Now()
+	var subjectErr error
+	subjectDone := make(chan struct{})
+	go func() {
+		defer close(subjectDone)
+		if subjectFrom != "" {
+			var resolved string
+			resolved, subjectErr = resolveSubject(ctx, query, subjectFrom)
+			if subjectErr == nil && resolved != "" {
+				subject = resolved
+			}
 		}
+	}()
+	// Resolve status from artifact.
+	res, err := resolve(ctx, query)
+	<-subjectDone
+	if err == nil && subjectErr != nil {
+		err = subjectErr
+	}
+	if err == errNotInstalled && fallback == "" {
+		// Tell README readers why the badge is missing.
+		failWith(err.Error(), notInstalledText)
+		return
 	}
-	if runID == 0 {
-		http.Error(w, "No run found", http.StatusBadRequest)
+	if err != nil {
+		fail(err.Error())
 		return
 	}
-	// Get artifacts.
-	artifacts, _, err := repoClient.Actions.ListWorkflowRunArtifacts(ctx, owner, repo, runID, &github.ListOptions{})
+	// Private badges granted by address or header must not be replayed to others.
+	if res.Private && !grantedByQuery {
+		cacheable = false
+	}
+	var split string
+	if spec := r.FormValue("split"); spec != "" && !res.Running {
+		split, err = resolveSplit(ctx, query, res, spec)
+		if err != nil {
+			fail(err.Error())
+			return
+		}
+	}
+	source, value := query.key(), res.key()+"\x00"+split
+	renders.invalidate(source, value)
+	if res.Run != nil {
+		w.Header().Set("X-AB-Run-ID", strconv.FormatInt(res.Run.GetID(), 10))
+		w.Header().Set("X-AB-Run-URL", res.Run.GetHTMLURL())
+	}
+	if res.ArtifactID != 0 {
+		w.Header().Set("X-AB-Artifact", strconv.FormatInt(res.ArtifactID, 10))
+	}
+	if query.Source != "" {
+		w.Header().Set("X-AB-Source", query.Source)
+	}
+	w.Header().Set("X-AB-Resolved-At", res.ResolvedAt.UTC().Format(http.TimeFormat))
+	if res.Unsafe {
+		w.Header().Set("X-AB-Unsafe-Text", "1")
+	}
+	status, color, err := displayStatus(r, subject, query, res)
 	if err != nil {
-		http.Error(w, "Failed to get artifacts", http.StatusBadRequest)
+		fail(err.Error())
 		return
 	}
-	// Find artifact matching name.
-	var downloadURL string
-	for _, artifact := range artifacts.Artifacts {
-		if artifact.GetName() == "badge_"+badgeName {
-			downloadURL = artifact.GetArchiveDownloadURL()
-			break
+	var logo string
+	if param := r.FormValue("logo"); param != "" {
+		var private bool
+		logo, private, err = resolveLogo(ctx, query, param)
+		if err != nil {
+			fail(err.Error())
+			return
+		}
+		if private && !grantedByQuery {
+			cacheable = false
+		}
+	}
+	var spark []float64
+	if boolParam(r, "sparkline") && !res.Running {
+		spark, err = sparkValues(res)
+		if err != nil {
+			fail(err.Error())
+			return
 		}
 	}
-	if downloadURL == "" {
-		http.Error(w, "Artifact not found in "+strconv.FormatInt(runID, 10), http.StatusBadRequest)
+	lookupName := "github"
+	if query.Source != "" {
+		lookupName = "source"
+	}
+	timing.add(lookupName, "", time.Since(lookupStart))
+	// Create badge.
+	labels := newTemplateData(status, subject, query, res)
+	badge := look
+	badge.Subject = expandPlaceholders(r, subject, labels)
+	badge.Status = status
+	badge.Color = color
+	badge.Label = expandPlaceholders(r, r.FormValue("label"), labels)
+	badge.List = r.FormValue("list")
+	badge.Icon = r.FormValue("icon")
+	badge.IconPos = r.FormValue("iconPos")
+	badge.Icon2 = r.FormValue("icon2")
+	badge.Logo = logo
+	badge.Split = split
+	badge.SplitColor = r.FormValue("color2")
+	badge.Sparkline = spark
+	badge.Running = res.Running
+	// Render badge.
+	if !cacheable {
+		w.Header().Set("Cache-Control", "private, no-cache")
+		writeBadge(ctx, w, &badge, format, timing)
 		return
 	}
-	status, err := loadArtifact(ctx, repoClient.Client(), downloadURL)
+	rendering, err := timing.renderBadge(ctx, &badge, format)
 	if err != nil {
-		http.Error(w, "Failed to download artifact: "+err.Error(), http.StatusBadRequest)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	// Create badge.
-	badge := Badge{
-		Subject: subject,
-		Status:  status,
-		Color:   r.FormValue("color"),
-		Label:   r.FormValue("label"),
-		List:    r.FormValue("list"),
-		Icon:    r.FormValue("icon"),
-	}
-	// Redirect to badge URL.
-	http.Redirect(w, r, badge.URL(), http.StatusSeeOther)
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(renderCacheTTL.Seconds())))
+	rendering.setHeaders(w.Header())
+	// Timing headers are set after caching since they differ per response.
+	entry := renders.put(cacheKey, source, value, w.Header(), rendering.status(), rendering.Body)
+	timing.setHeaders(w.Header())
+	entry.writeTo(w)
 }
 
-// Badge is a GitHub Badge.
-type Badge struct {
-	Subject string
-	Status  string
-	Color   string
-	Label   string
-	List    string
-	Icon    string
+// validFormat reports whether format names a supported output format.
+func validFormat(format string) bool {
+	switch format {
+	case "", "svg", "png", "webp", "json":
+		return true
+	}
+	return false
 }
 
-// URL returns the link pointing to the badge image.
-// Service provided by https://badgen.net/
-func (b *Badge) URL() string {
-	values := make(url.Values)
-	if b.Color != "" {
-		values.Set("color", b.Color)
-	}
-	if b.Label != "" {
-		values.Set("label", b.Label)
-	}
-	if b.List != "" {
-		values.Set("list", b.List)
-	}
-	if b.Icon != "" {
-		values.Set("icon", b.Icon)
-	}
-	return fmt.Sprintf("https://badgen.net/badge/%s/%s?%s",
-		url.PathEscape(b.Subject),
-		url.PathEscape(b.Status),
-		values.Encode())
+// negotiateFormat returns the output format of a request, its format param
+// unless that asks for PNG from a client accepting WebP, which gets WebP.
+func negotiateFormat(w http.ResponseWriter, r *http.Request) string {
+	format := r.FormValue("format")
+	if format != "png" {
+		return format
+	}
+	w.Header().Set("Vary", "Accept")
+	if strings.Contains(r.Header.Get("Accept"), webpContentType) {
+		return "webp"
+	}
+	return format
 }
 
-func githubPrivateKey() []byte {
-	ctx := context.Background()
-	client, err := secretmanager.NewClient(ctx)
-	if err != nil {
-		log.Fatalf("Failed to create secret manager client: %s", err)
-	}
-	request := &secretmanagerpb.AccessSecretVersionRequest{
-		Name: os.Getenv(envPrivateKeySecret),
-	}
-	secret, err := client.AccessSecretVersion(ctx, request)
+// writeBadge responds with the rendered badge.
+func writeBadge(ctx context.Context, w http.ResponseWriter, badge *Badge, format string, timing *serverTiming) {
+	rendering, err := timing.renderBadge(ctx, badge, format)
 	if err != nil {
-		log.Fatalf("Failed to retrieve GitHub private key: %s", err)
+		// Callers may have set headers caching the badge.
+		w.Header().Set("Cache-Control", "no-cache")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
 	}
-	return secret.GetPayload().GetData()
+	rendering.setHeaders(w.Header())
+	timing.setHeaders(w.Header())
+	w.WriteHeader(rendering.status())
+	w.Write(rendering.Body)
 }
 
-func newGitHubTransport(appID int64, privateKey []byte) *ghinstallation.AppsTransport {
-	tr, err := ghinstallation.NewAppsTransport(http.DefaultTransport, appID, privateKey)
+func githubPrivateKey(secretName string) ([]byte, error) {
+	key, err := accessSecret(secretName)
 	if err != nil {
-		log.Fatalf("Failed to create OAuth transport: %s", err)
+		return nil, fmt.Errorf("failed to retrieve GitHub private key: %w", err)
 	}
-	return tr
+	return key, nil
 }
 
-func loadArtifact(ctx context.Context, client *http.Client, downloadURL string) (string, error) {
-	// Submit download request.
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, downloadURL, nil)
-	if err != nil {
-		return "", err
-	}
-	//req.Header.Set("accept", "application/zip")
-	res, err := client.Do(req)
-	if err != nil {
-		return "", err
-	}
-	defer res.Body.Close()
-	if res.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("status %s", res.Status)
-	}
-	// Read body (1K max).
-	zipBuf, err := ioutil.ReadAll(io.LimitReader(res.Body, 1024))
-	if err != nil {
-		return "", err
-	}
-	// Read ZIP header.
-	rd, err := zip.NewReader(bytes.NewReader(zipBuf), int64(len(zipBuf)))
+// accessSecret reads a secret version from Secret Manager.
+func accessSecret(secretName string) ([]byte, error) {
+	ctx := context.Background()
+	client, err := secretmanager.NewClient(ctx)
 	if err != nil {
-		return "", err
+		return nil, fmt.Errorf("failed to create secret manager client: %w", err)
 	}
-	// Find first file.
-	var zipFile *zip.File
-	for _, currentZipFile := range rd.File {
-		if !currentZipFile.FileInfo().IsDir() {
-			zipFile = currentZipFile
-		}
-	}
-	if zipFile == nil {
-		return "null", nil
+	defer client.Close()
+	request := &secretmanagerpb.AccessSecretVersionRequest{
+		Name: secretName,
 	}
-	// Open file in ZIP.
-	stream, err := zipFile.Open()
+	secret, err := client.AccessSecretVersion(ctx, request)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	// Extract first line.
-	bodyBuf, err := ioutil.ReadAll(io.LimitReader(stream, 128))
+	return secret.GetPayload().GetData(), nil
+}
+
+func newGitHubTransport(appID int64, privateKey []byte) (*ghinstallation.AppsTransport, error) {
+	tr, err := ghinstallation.NewAppsTransport(upstreamTransport, appID, privateKey)
 	if err != nil {
-		return "", err
-	}
-	lines := strings.SplitN(string(bodyBuf), "\n", 2)
-	if len(lines) == 0 {
-		return "null", nil
-	}
-	firstLine := strings.TrimSpace(lines[0])
-	if firstLine == "" {
-		return "null", nil
+		return nil, fmt.Errorf("failed to create OAuth transport: %w", err)
 	}
-	return firstLine, nil
+	return tr, nil
 }
@@ -0,0 +1,73 @@
+package badge
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// staticMaxAge is how long clients may cache static badges.
+const staticMaxAge = 24 * time.Hour
+
+// StaticBadgeHTTP is a HTTP cloud function serving static badges from
+// badgen.net style paths, /badge/:subject/:status/:color, so that badgen
+// URLs can be pointed at this service. It takes the icon, label, list,
+// style and scale params of badgen, and the other rendering params of
+// GenBadgeHTTP.
+func StaticBadgeHTTP(w http.ResponseWriter, r *http.Request) {
+	setup()
+	if !requireFeature(w, featureStatic) {
+		return
+	}
+	timing := newServerTiming()
+	path := strings.TrimPrefix(strings.TrimPrefix(r.URL.EscapedPath(), "/"), "badge/")
+	parts := strings.Split(path, "/")
+	if len(parts) < 2 || len(parts) > 3 {
+		http.Error(w, "Expected /badge/:subject/:status/:color", http.StatusBadRequest)
+		return
+	}
+	for i, part := range parts {
+		unescaped, err := url.PathUnescape(part)
+		if err != nil {
+			http.Error(w, "Invalid path", http.StatusBadRequest)
+			return
+		}
+		parts[i] = unescaped
+	}
+	format := negotiateFormat(w, r)
+	if !validFormat(format) {
+		http.Error(w, "Unknown format", http.StatusBadRequest)
+		return
+	}
+	badge := Badge{
+		Subject: parts[0],
+		Status:  parts[1],
+		Label:   r.FormValue("label"),
+		List:    r.FormValue("list"),
+		Icon:    r.FormValue("icon"),
+		Style:   r.FormValue("style"),
+		Palette: r.FormValue("palette"),
+		Alt:     r.FormValue("alt"),
+	}
+	if len(parts) == 3 {
+		badge.Color = parts[2]
+	}
+	if !validStyle(badge.Style) {
+		http.Error(w, "Unknown style", http.StatusBadRequest)
+		return
+	}
+	if !validPalette(badge.Palette) {
+		http.Error(w, "Unknown palette", http.StatusBadRequest)
+		return
+	}
+	var err error
+	badge.Scale, err = parseScale(r, badge.Style)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(staticMaxAge.Seconds())))
+	writeBadge(r.Context(), w, &badge, format, timing)
+}
@@ -0,0 +1,195 @@
+package badge
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/v37/github"
+)
+
+const (
+	// maxMatrixBranches caps the branches compared by BranchesHTTP.
+	maxMatrixBranches = 100
+	// matrixConcurrency bounds the branches resolved in parallel.
+	matrixConcurrency = 8
+	// matrixCacheTTL is how long a branch comparison is served.
+	matrixCacheTTL = time.Minute
+)
+
+// matrices shares branch comparisons, each of which resolves many badges.
+var matrices = newCoalescer(matrixCacheTTL)
+
+// branchValue is one row of the branch comparison matrix.
+type branchValue struct {
+	Branch string `json:"branch"`
+	Value  string `json:"value,omitempty"`
+	RunURL string `json:"runUrl,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+var branchesTemplate = template.Must(template.New("branches").Parse(`<!DOCTYPE html>
+<html>
+<head><title>{{.Badge}} of {{.Repo}}</title></head>
+<body>
+<h1>{{.Badge}} of {{.Repo}} by branch</h1>
+<table>
+<tr><th>Branch</th><th>Value</th></tr>
+{{range .Rows}}<tr><td>{{.Branch}}</td><td>{{if .Error}}<em>{{.Error}}</em>{{else if .RunURL}}<a href="{{.RunURL}}">{{.Value}}</a>{{else}}{{.Value}}{{end}}</td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+// BranchesHTTP is a HTTP cloud function that compares one badge's value
+// across the branches of a repo. It takes the same params as GenBadgeHTTP
+// except for branch, and serves HTML to browsers and JSON otherwise.
+func BranchesHTTP(w http.ResponseWriter, r *http.Request) {
+	setup()
+	if !requireFeature(w, featureBranches) {
+		return
+	}
+	signed, err := verifySignedLink(r, time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	ctx := r.Context()
+	query, err := decodeBadgeQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if query.ArtifactID != 0 {
+		http.Error(w, "Artifact IDs can't be compared across branches", http.StatusBadRequest)
+		return
+	}
+	var grantedByQuery bool
+	query.allowPrivate, grantedByQuery = requestAccess(r, signed, query.Host, query.Owner)
+	// The repo is opened once for all branches, since each would
+	// otherwise look up the installation and visibility again.
+	client, private, err := openRepo(ctx, query)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	// The branch param is ignored, so it must not split the cache.
+	query.Branch = ""
+	key := strings.Join([]string{"matrix", query.key(), strconv.FormatBool(query.allowPrivate)}, "\x00")
+	val, err := matrices.do(ctx, key, func(ctx context.Context) (interface{}, error) {
+		return branchMatrix(ctx, client, private, query)
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	rows := val.([]branchValue)
+	// Private matrices granted by address or header must not be replayed to others.
+	if private && !grantedByQuery {
+		w.Header().Set("Cache-Control", "private, no-cache")
+	} else {
+		w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(matrixCacheTTL.Seconds())))
+	}
+	w.Header().Set("X-Robots-Tag", "noindex")
+	if wantsHTML(r) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		branchesTemplate.Execute(w, map[string]interface{}{
+			"Repo":  query.Owner + "/" + query.Repo,
+			"Badge": query.Badge,
+			"Rows":  rows,
+		})
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rows)
+}
+
+// branchMatrix resolves the badge of a query on every branch of its repo,
+// opened by openRepo. The upstream repo of a fork is opened at most once.
+func branchMatrix(ctx context.Context, client *github.Client, private bool, query *badgeQuery) ([]branchValue, error) {
+	// List branches.
+	var branches []*github.Branch
+	err := forEachPage(func(opts *github.ListOptions) (*github.Response, error) {
+		list, res, err := client.Repositories.ListBranches(ctx, query.Owner, query.Repo, &github.BranchListOptions{ListOptions: *opts})
+		if err != nil {
+			return nil, err
+		}
+		branches = append(branches, list...)
+		if len(branches) >= maxMatrixBranches {
+			branches = branches[:maxMatrixBranches]
+			res.NextPage = 0
+		}
+		return res, nil
+	})
+	if err != nil {
+		return nil, errors.New("Failed to list branches")
+	}
+	var upstream struct {
+		once    sync.Once
+		fork    bool
+		query   badgeQuery
+		client  *github.Client
+		private bool
+		err     error
+	}
+	resolveBranch := func(branchQuery *badgeQuery) (*resolution, error) {
+		if query.Source != "" {
+			return resolve(ctx, branchQuery)
+		}
+		res, err := resolveInRepo(ctx, client, private, branchQuery)
+		if err == nil || !query.Upstream {
+			return res, err
+		}
+		upstream.once.Do(func() {
+			parent := forkParent(ctx, query.Host, query.Owner, query.Repo)
+			if parent == nil {
+				return
+			}
+			upstream.fork = true
+			upstream.query = *query
+			upstream.query.Owner = parent.GetOwner().GetLogin()
+			upstream.query.Repo = parent.GetName()
+			upstream.client, upstream.private, upstream.err = openRepo(ctx, &upstream.query)
+		})
+		if !upstream.fork {
+			return nil, err
+		}
+		if upstream.err != nil {
+			return nil, upstream.err
+		}
+		upstreamQuery := upstream.query
+		upstreamQuery.Branch = branchQuery.Branch
+		return resolveInRepo(ctx, upstream.client, upstream.private, &upstreamQuery)
+	}
+	// Resolve badge on every branch.
+	rows := make([]branchValue, len(branches))
+	sem := make(chan struct{}, matrixConcurrency)
+	var wg sync.WaitGroup
+	for i, branch := range branches {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, branch string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			branchQuery := *query
+			branchQuery.Branch = branch
+			rows[i].Branch = branch
+			res, err := resolveBranch(&branchQuery)
+			if err != nil {
+				rows[i].Error = err.Error()
+				return
+			}
+			rows[i].Value = res.Status
+			rows[i].RunURL = res.Run.GetHTMLURL()
+		}(i, branch.GetName())
+	}
+	wg.Wait()
+	return rows, nil
+}
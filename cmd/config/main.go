@@ -0,0 +1,31 @@
+// Command config prints the effective configuration as JSON and validates it.
+//
+// Secret values are redacted. The exit status is non-zero if the
+// configuration is invalid, so pipelines can assert deployed config.
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	badge "github.com/terorie/action-badge"
+)
+
+func main() {
+	config, err := badge.LoadConfig()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to load config:", err)
+		os.Exit(1)
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(config.Redacted()); err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to encode config:", err)
+		os.Exit(1)
+	}
+	if err := config.Validate(); err != nil {
+		fmt.Fprintln(os.Stderr, "Invalid config:", err)
+		os.Exit(1)
+	}
+}
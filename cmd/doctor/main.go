@@ -0,0 +1,50 @@
+// Command doctor checks that the service is configured correctly.
+//
+// It verifies the credentials, mints an App JWT, fetches an installation
+// token and optionally resolves a sample badge, then prints a report.
+//
+//	doctor -sample 'repo=owner/repo&branch=main&run=CI&badge=coverage'
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+
+	badge "github.com/terorie/action-badge"
+)
+
+func main() {
+	sampleFlag := flag.String("sample", "", "Badge query string to resolve end-to-end")
+	flag.Parse()
+	var sample url.Values
+	if *sampleFlag != "" {
+		var err error
+		sample, err = url.ParseQuery(*sampleFlag)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Invalid sample:", err)
+			os.Exit(2)
+		}
+	}
+	failed := false
+	for _, result := range badge.Diagnose(context.Background(), sample) {
+		status := "ok  "
+		if result.Err != nil {
+			status = "FAIL"
+			failed = true
+		}
+		line := fmt.Sprintf("%s %s", status, result.Check)
+		if result.Detail != "" {
+			line += " (" + result.Detail + ")"
+		}
+		if result.Err != nil {
+			line += ": " + result.Err.Error()
+		}
+		fmt.Println(line)
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,43 @@
+// Command sign creates expiring share links for badges.
+//
+// The link is signed with the key named by AB_SIGNING_KEY_SECRET_NAME.
+//
+//	sign -ttl 720h -link status-page 'repo=owner/repo&branch=main&run=CI&badge=coverage&subject=coverage'
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"time"
+
+	badge "github.com/terorie/action-badge"
+)
+
+func main() {
+	base := flag.String("base", "", "Badge function URL to prefix the signed query with")
+	ttl := flag.Duration("ttl", 24*time.Hour, "Link lifetime")
+	linkID := flag.String("link", "", "Link ID for revocation via AB_REVOKED_LINKS")
+	flag.Parse()
+	if flag.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "Usage: sign [flags] <badge query>")
+		os.Exit(2)
+	}
+	query, err := url.ParseQuery(flag.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Invalid query:", err)
+		os.Exit(2)
+	}
+	key, err := badge.LoadSigningKey()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	signed := badge.SignLink(key, query, time.Now().Add(*ttl), *linkID)
+	if *base != "" {
+		fmt.Println(*base + "?" + signed.Encode())
+	} else {
+		fmt.Println(signed.Encode())
+	}
+}
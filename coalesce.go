@@ -0,0 +1,124 @@
+package badge
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// coalesceWindow is how long shared lookup results are reused.
+const coalesceWindow = 30 * time.Second
+
+// coalescerPurgeSize is the entry count at which expired entries get dropped.
+const coalescerPurgeSize = 1024
+
+// coalescer shares the results of identical lookups,
+// both while they are in flight and for a short window afterwards.
+type coalescer struct {
+	mu      sync.Mutex
+	window  time.Duration
+	entries map[string]*coalescerEntry
+}
+
+type coalescerEntry struct {
+	done    chan struct{}
+	val     interface{}
+	err     error
+	expires time.Time
+	// waiters counts callers still waiting on an in-flight lookup.
+	waiters int
+	ctx     context.Context
+	cancel  context.CancelFunc
+}
+
+func newCoalescer(window time.Duration) *coalescer {
+	return &coalescer{
+		window:  window,
+		entries: make(map[string]*coalescerEntry),
+	}
+}
+
+// do returns the shared result for key, calling fn if there is none.
+// Failed lookups are not shared beyond callers already waiting on them.
+// The context passed to fn is canceled once all waiting callers are gone.
+func (c *coalescer) do(ctx context.Context, key string, fn func(ctx context.Context) (interface{}, error)) (interface{}, error) {
+	c.mu.Lock()
+	entry, ok := c.entries[key]
+	if ok && !entry.expires.IsZero() && time.Now().After(entry.expires) {
+		ok = false
+	}
+	if ok && entry.expires.IsZero() && entry.ctx.Err() != nil {
+		// Abandoned by all previous callers.
+		ok = false
+	}
+	if ok {
+		if !entry.expires.IsZero() {
+			c.mu.Unlock()
+			return entry.val, entry.err
+		}
+		entry.waiters++
+		c.mu.Unlock()
+		return c.wait(ctx, entry)
+	}
+	if len(c.entries) >= coalescerPurgeSize {
+		c.purge()
+	}
+	entry = &coalescerEntry{done: make(chan struct{}), waiters: 1}
+	entry.ctx, entry.cancel = context.WithCancel(context.Background())
+	c.entries[key] = entry
+	c.mu.Unlock()
+
+	go c.run(key, entry, fn)
+	return c.wait(ctx, entry)
+}
+
+// run performs the lookup of an entry.
+func (c *coalescer) run(key string, entry *coalescerEntry, fn func(ctx context.Context) (interface{}, error)) {
+	val, err := fn(entry.ctx)
+
+	c.mu.Lock()
+	entry.val, entry.err = val, err
+	if entry.err != nil {
+		if c.entries[key] == entry {
+			delete(c.entries, key)
+		}
+	} else {
+		entry.expires = time.Now().Add(c.window)
+	}
+	c.mu.Unlock()
+	entry.cancel()
+	close(entry.done)
+}
+
+// wait blocks until the lookup of an entry finishes or ctx is done.
+func (c *coalescer) wait(ctx context.Context, entry *coalescerEntry) (interface{}, error) {
+	select {
+	case <-entry.done:
+		return entry.val, entry.err
+	case <-ctx.Done():
+		c.mu.Lock()
+		entry.waiters--
+		if entry.waiters == 0 {
+			entry.cancel()
+		}
+		c.mu.Unlock()
+		return nil, ctx.Err()
+	}
+}
+
+// purge drops expired entries. The caller must hold c.mu.
+func (c *coalescer) purge() {
+	now := time.Now()
+	for key, entry := range c.entries {
+		if !entry.expires.IsZero() && now.After(entry.expires) {
+			delete(c.entries, key)
+		}
+	}
+}
+
+// size returns the number of shared results, for the status page.
+func (c *coalescer) size() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.entries)
+}
@@ -0,0 +1,198 @@
+package badge
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCoalescerShared(t *testing.T) {
+	c := newCoalescer(coalesceWindow)
+	var calls int32
+	release := make(chan struct{})
+	fn := func(ctx context.Context) (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return "value", nil
+	}
+	const callers = 16
+	var wg sync.WaitGroup
+	results := make([]interface{}, callers)
+	errs := make([]error, callers)
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], errs[i] = c.do(context.Background(), "key", fn)
+		}(i)
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Errorf("lookups: got %d, want 1", n)
+	}
+	for i := range results {
+		if results[i] != "value" || errs[i] != nil {
+			t.Errorf("caller %d: got %v, %v", i, results[i], errs[i])
+		}
+	}
+
+	// Finished results are reused within the window.
+	if val, err := c.do(context.Background(), "key", fn); val != "value" || err != nil {
+		t.Errorf("reuse: got %v, %v", val, err)
+	}
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Errorf("lookups after reuse: got %d, want 1", n)
+	}
+	// Other keys are looked up separately.
+	if val, err := c.do(context.Background(), "other", fn); val != "value" || err != nil {
+		t.Errorf("other key: got %v, %v", val, err)
+	}
+	if n := atomic.LoadInt32(&calls); n != 2 {
+		t.Errorf("lookups for other key: got %d, want 2", n)
+	}
+}
+
+func TestCoalescerCancel(t *testing.T) {
+	c := newCoalescer(coalesceWindow)
+	started := make(chan struct{})
+	release := make(chan struct{})
+	canceled := make(chan struct{})
+	fn := func(ctx context.Context) (interface{}, error) {
+		close(started)
+		select {
+		case <-release:
+			return "value", nil
+		case <-ctx.Done():
+			close(canceled)
+			return nil, ctx.Err()
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	first := make(chan error, 1)
+	go func() {
+		_, err := c.do(ctx, "key", fn)
+		first <- err
+	}()
+	<-started
+	second := make(chan interface{}, 1)
+	go func() {
+		val, _ := c.do(context.Background(), "key", fn)
+		second <- val
+	}()
+	// Wait for the second caller to join.
+	for {
+		c.mu.Lock()
+		waiters := c.entries["key"].waiters
+		c.mu.Unlock()
+		if waiters == 2 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	cancel()
+	if err := <-first; err != context.Canceled {
+		t.Errorf("canceled caller: got %v, want %v", err, context.Canceled)
+	}
+	select {
+	case <-canceled:
+		t.Fatal("lookup canceled while a caller still waits")
+	case <-time.After(10 * time.Millisecond):
+	}
+	close(release)
+	if val := <-second; val != "value" {
+		t.Errorf("remaining caller: got %v, want value", val)
+	}
+}
+
+func TestCoalescerAbandoned(t *testing.T) {
+	c := newCoalescer(coalesceWindow)
+	canceled := make(chan struct{})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		_, err := c.do(ctx, "key", func(ctx context.Context) (interface{}, error) {
+			cancel()
+			<-ctx.Done()
+			close(canceled)
+			return nil, ctx.Err()
+		})
+		done <- err
+	}()
+	if err := <-done; err != context.Canceled {
+		t.Errorf("got %v, want %v", err, context.Canceled)
+	}
+	select {
+	case <-canceled:
+	case <-time.After(time.Second):
+		t.Fatal("lookup not canceled after all callers left")
+	}
+
+	// An abandoned lookup is not joined by later callers.
+	val, err := c.do(context.Background(), "key", func(ctx context.Context) (interface{}, error) {
+		return "value", nil
+	})
+	if val != "value" || err != nil {
+		t.Errorf("after abandon: got %v, %v", val, err)
+	}
+}
+
+func TestCoalescerExpiry(t *testing.T) {
+	c := newCoalescer(coalesceWindow)
+	var calls int32
+	fn := func(ctx context.Context) (interface{}, error) {
+		return atomic.AddInt32(&calls, 1), nil
+	}
+	if val, _ := c.do(context.Background(), "key", fn); val != int32(1) {
+		t.Fatalf("got %v, want 1", val)
+	}
+	c.mu.Lock()
+	expires := c.entries["key"].expires
+	c.mu.Unlock()
+	if d := time.Until(expires); d <= coalesceWindow-time.Second || d > coalesceWindow {
+		t.Errorf("expires in %v, want %v", d, coalesceWindow)
+	}
+	if val, _ := c.do(context.Background(), "key", fn); val != int32(1) {
+		t.Errorf("within window: got %v, want 1", val)
+	}
+
+	// Pretend the window has passed.
+	c.mu.Lock()
+	c.entries["key"].expires = time.Now().Add(-time.Millisecond)
+	c.mu.Unlock()
+	if val, _ := c.do(context.Background(), "key", fn); val != int32(2) {
+		t.Errorf("after window: got %v, want 2", val)
+	}
+
+	c.mu.Lock()
+	c.entries["key"].expires = time.Now().Add(-time.Millisecond)
+	c.purge()
+	c.mu.Unlock()
+	if n := c.size(); n != 0 {
+		t.Errorf("size after purge: got %d, want 0", n)
+	}
+}
+
+func TestCoalescerError(t *testing.T) {
+	c := newCoalescer(coalesceWindow)
+	errLookup := errors.New("lookup failed")
+	if _, err := c.do(context.Background(), "key", func(ctx context.Context) (interface{}, error) {
+		return nil, errLookup
+	}); err != errLookup {
+		t.Errorf("got %v, want %v", err, errLookup)
+	}
+	if n := c.size(); n != 0 {
+		t.Errorf("size after error: got %d, want 0", n)
+	}
+	if val, err := c.do(context.Background(), "key", func(ctx context.Context) (interface{}, error) {
+		return "value", nil
+	}); val != "value" || err != nil {
+		t.Errorf("retry: got %v, %v", val, err)
+	}
+}
@@ -0,0 +1,78 @@
+package badge
+
+import (
+	"context"
+	"errors"
+	"strings"
+)
+
+// subjectRelease is the subjectFrom spec for the latest release tag.
+const subjectRelease = "release"
+
+// splitLine is the split spec for the second line of the artifact.
+const splitLine = "line"
+
+// resolveSubject resolves a badge subject from a second source.
+// The spec is either "release" for the latest release tag,
+// or "<run>/<badge>" for another badge artifact on the same branch.
+func resolveSubject(ctx context.Context, query *badgeQuery, spec string) (string, error) {
+	if spec == subjectRelease {
+		return latestReleaseTag(ctx, query)
+	}
+	return resolveArtifactSpec(ctx, query, spec, "subjectFrom")
+}
+
+// resolveArtifactSpec resolves the status of another badge artifact on the
+// branch of query, selected by a "<run>/<badge>" spec from the param key.
+func resolveArtifactSpec(ctx context.Context, query *badgeQuery, spec, key string) (string, error) {
+	sep := strings.LastIndex(spec, "/")
+	if sep <= 0 || sep == len(spec)-1 {
+		return "", errors.New("Invalid " + key + " key")
+	}
+	if query.Branch == "" {
+		return "", errors.New("Missing branch key")
+	}
+	subjectQuery := *query
+	subjectQuery.Run = spec[:sep]
+	subjectQuery.Badge = spec[sep+1:]
+	subjectQuery.ArtifactID = 0
+	subjectQuery.Source = ""
+	res, err := resolve(ctx, &subjectQuery)
+	if err != nil {
+		return "", err
+	}
+	return res.Status, nil
+}
+
+// resolveSplit resolves the split status of a badge from the spec,
+// either "line" for the second line of the resolved artifact
+// or "<run>/<badge>" for another badge artifact on the same branch.
+func resolveSplit(ctx context.Context, query *badgeQuery, res *resolution, spec string) (string, error) {
+	if spec != splitLine {
+		return resolveArtifactSpec(ctx, query, spec, "split")
+	}
+	if len(res.Lines) == 0 {
+		return "", errors.New("No second line found")
+	}
+	return res.Lines[0], nil
+}
+
+// latestReleaseTag returns the tag of the latest release of the queried repo.
+func latestReleaseTag(ctx context.Context, query *badgeQuery) (string, error) {
+	client, err := newRepoClient(ctx, query.Host, query.Owner, query.Repo)
+	if err != nil {
+		return "", err
+	}
+	private, err := isPrivateRepo(ctx, client, query.Owner, query.Repo)
+	if err != nil {
+		return "", errors.New("Failed to get repo")
+	}
+	if private && !query.allowPrivate {
+		return "", errors.New("Repo is private")
+	}
+	release, _, err := client.Repositories.GetLatestRelease(ctx, query.Owner, query.Repo)
+	if err != nil {
+		return "", errors.New("No release found")
+	}
+	return redact(release.GetTagName()), nil
+}
@@ -0,0 +1,384 @@
+package badge
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	envPrivateKeySecret = "AB_PRIVATE_KEY_SECRET_NAME"
+	envGHAppID          = "AB_GH_APP_ID"
+	envDebugUpstream    = "AB_DEBUG_UPSTREAM"
+	envMode             = "AB_MODE"
+	envFaultErrorRate   = "AB_FAULT_ERROR_RATE"
+	envFaultLatency     = "AB_FAULT_LATENCY"
+	envSigningKeySecret = "AB_SIGNING_KEY_SECRET_NAME"
+	envRevokedLinks     = "AB_REVOKED_LINKS"
+	envAPIKeyHashes     = "AB_API_KEY_HASHES"
+	envAllowedIPs       = "AB_PRIVATE_ALLOWED_IPS"
+	envRedactDefaults   = "AB_REDACT_DEFAULTS"
+	envRedactPatterns   = "AB_REDACT_PATTERNS"
+	envMaxSubjectLen    = "AB_MAX_SUBJECT_LEN"
+	envMaxStatusLen     = "AB_MAX_STATUS_LEN"
+	envUnsafeText       = "AB_UNSAFE_TEXT"
+	envSonarURL         = "AB_SONAR_URL"
+	envSonarTokenSecret = "AB_SONAR_TOKEN_SECRET_NAME"
+	envProbeURLs        = "AB_PROBE_URLS"
+	envRenderer         = "AB_RENDERER"
+	envRendererURL      = "AB_RENDERER_URL"
+	envFeatures         = "AB_FEATURES"
+	envRendererProxy    = "AB_RENDERER_PROXY"
+	envMaintenance      = "AB_MAINTENANCE"
+	envMaintenanceRepos = "AB_MAINTENANCE_REPOS"
+	envEnterpriseHosts  = "AB_GHES_HOSTS"
+	envTrustedProxies   = "AB_TRUSTED_PROXIES"
+	envPalette          = "AB_PALETTE"
+	envEmptyText        = "AB_EMPTY_TEXT"
+	envEmptyColor       = "AB_EMPTY_COLOR"
+	envLiveInterval     = "AB_LIVE_INTERVAL"
+)
+
+// modeProduction is the default mode, in which fault injection is refused.
+const modeProduction = "production"
+
+// redactedValue replaces secret config values when exporting.
+const redactedValue = "REDACTED"
+
+// Config is the effective configuration of the service.
+// Fields tagged `secret:"true"` hold credentials or name where they are kept.
+type Config struct {
+	// PrivateKeySecret is the Secret Manager version holding the App private key.
+	PrivateKeySecret string `json:"privateKeySecret" secret:"true"`
+	// AppID is the ID of the GitHub App.
+	AppID int64 `json:"appId"`
+	// DebugUpstream logs every GitHub and artifact request.
+	DebugUpstream bool `json:"debugUpstream"`
+	// Mode is "production" unless set otherwise.
+	Mode string `json:"mode"`
+	// FaultErrorRate is the fraction of upstream requests failed on purpose.
+	FaultErrorRate float64 `json:"faultErrorRate"`
+	// FaultLatency is added to every upstream request.
+	FaultLatency time.Duration `json:"faultLatency"`
+	// SigningKeySecret is the Secret Manager version holding the share link key.
+	SigningKeySecret string `json:"signingKeySecret" secret:"true"`
+	// RevokedLinks lists the IDs of share links that are no longer valid.
+	// Revoking a link takes a redeploy, as instances share no state.
+	RevokedLinks []string `json:"revokedLinks" secret:"true"`
+	// APIKeyHashes are hex SHA-256 hashes of keys granting private repo access
+	// and use of the API. Entries of the form owner=hash or host/owner=hash
+	// limit a key to the repos and org of one owner.
+	APIKeyHashes []string `json:"apiKeyHashes" secret:"true"`
+	// AllowedIPs are addresses or CIDR ranges granted private repo access.
+	AllowedIPs []string `json:"allowedIPs"`
+	// TrustedProxies are addresses or CIDR ranges of proxies whose
+	// Forwarded and X-Forwarded-For headers identify the client.
+	TrustedProxies []string `json:"trustedProxies"`
+	// RedactDefaults enables the built-in token and email redaction patterns.
+	RedactDefaults bool `json:"redactDefaults"`
+	// RedactPatterns are extra regular expressions redacted from values.
+	RedactPatterns []string `json:"redactPatterns"`
+	// MaxSubjectLen and MaxStatusLen limit rendered text, 0 disables the limit.
+	MaxSubjectLen int `json:"maxSubjectLen"`
+	MaxStatusLen  int `json:"maxStatusLen"`
+	// UnsafeText is the handling of bidi control and invisible characters
+	// in values: "allow", "flag", "strip" or "reject".
+	UnsafeText string `json:"unsafeText"`
+	// SonarURL is the SonarQube or SonarCloud instance of the sonar source.
+	SonarURL string `json:"sonarUrl"`
+	// SonarTokenSecret is the Secret Manager version holding a SonarQube token.
+	SonarTokenSecret string `json:"sonarTokenSecret" secret:"true"`
+	// ProbeURLs are the URLs the probe source may health check.
+	ProbeURLs []string `json:"probeUrls"`
+	// Renderer renders badges: "native", "badgen" or "shields".
+	Renderer string `json:"renderer"`
+	// Palette is the palette of color names of badges not asking for one.
+	Palette string `json:"palette"`
+	// EmptyText is the status of badges whose artifact holds no value.
+	EmptyText string `json:"emptyText"`
+	// EmptyColor is the color of those badges, their usual color if empty.
+	EmptyColor string `json:"emptyColor"`
+	// RendererURL is the base URL of a self-hosted badgen or shields instance.
+	RendererURL string `json:"rendererUrl"`
+	// RendererProxy serves images of external renderers instead of redirecting.
+	RendererProxy bool `json:"rendererProxy"`
+	// Features are the enabled optional features, all but opt-in ones if empty.
+	Features []string `json:"features"`
+	// Maintenance replaces the status of all badges while set.
+	Maintenance string `json:"maintenance"`
+	// MaintenanceRepos limits the maintenance override to these owner/repo names.
+	MaintenanceRepos []string `json:"maintenanceRepos"`
+	// EnterpriseHosts are GHES instances as "host=appID:secretName", where
+	// secretName is the Secret Manager version holding that App's private key.
+	EnterpriseHosts []string `json:"enterpriseHosts" secret:"true"`
+	// LiveInterval is how often open live badge pages resolve again.
+	LiveInterval time.Duration `json:"liveInterval"`
+}
+
+// LoadConfig reads the configuration from the environment.
+func LoadConfig() (*Config, error) {
+	config := &Config{
+		PrivateKeySecret: os.Getenv(envPrivateKeySecret),
+		Mode:             os.Getenv(envMode),
+		SigningKeySecret: os.Getenv(envSigningKeySecret),
+		RevokedLinks:     envList(envRevokedLinks),
+		APIKeyHashes:     envList(envAPIKeyHashes),
+		AllowedIPs:       envList(envAllowedIPs),
+		TrustedProxies:   envList(envTrustedProxies),
+		RedactDefaults:   true,
+		RedactPatterns:   envLines(envRedactPatterns),
+		MaxSubjectLen:    defaultMaxSubjectLen,
+		MaxStatusLen:     defaultMaxStatusLen,
+		UnsafeText:       os.Getenv(envUnsafeText),
+		SonarURL:         strings.TrimSuffix(os.Getenv(envSonarURL), "/"),
+		SonarTokenSecret: os.Getenv(envSonarTokenSecret),
+		ProbeURLs:        envList(envProbeURLs),
+		Renderer:         os.Getenv(envRenderer),
+		Palette:          os.Getenv(envPalette),
+		EmptyText:        os.Getenv(envEmptyText),
+		EmptyColor:       os.Getenv(envEmptyColor),
+		RendererURL:      strings.TrimSuffix(os.Getenv(envRendererURL), "/"),
+		Features:         envList(envFeatures),
+		Maintenance:      os.Getenv(envMaintenance),
+		MaintenanceRepos: envList(envMaintenanceRepos),
+		EnterpriseHosts:  envList(envEnterpriseHosts),
+		LiveInterval:     defaultLiveInterval,
+	}
+	if config.Renderer == "" {
+		config.Renderer = defaultRenderer
+	}
+	if config.Palette == "" {
+		config.Palette = "default"
+	}
+	if config.EmptyText == "" {
+		config.EmptyText = defaultEmptyText
+	}
+	if config.SonarURL == "" {
+		config.SonarURL = defaultSonarURL
+	}
+	if config.UnsafeText == "" {
+		config.UnsafeText = unsafeTextStrip
+	}
+	if config.Mode == "" {
+		config.Mode = modeProduction
+	}
+	if appID := os.Getenv(envGHAppID); appID != "" {
+		var err error
+		config.AppID, err = strconv.ParseInt(appID, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", envGHAppID, err)
+		}
+	}
+	if err := envBool(envDebugUpstream, &config.DebugUpstream); err != nil {
+		return nil, err
+	}
+	if err := envBool(envRendererProxy, &config.RendererProxy); err != nil {
+		return nil, err
+	}
+	if err := envBool(envRedactDefaults, &config.RedactDefaults); err != nil {
+		return nil, err
+	}
+	if err := envInt(envMaxSubjectLen, &config.MaxSubjectLen); err != nil {
+		return nil, err
+	}
+	if err := envInt(envMaxStatusLen, &config.MaxStatusLen); err != nil {
+		return nil, err
+	}
+	if err := envFloat(envFaultErrorRate, &config.FaultErrorRate); err != nil {
+		return nil, err
+	}
+	if err := envDuration(envFaultLatency, &config.FaultLatency); err != nil {
+		return nil, err
+	}
+	if err := envDuration(envLiveInterval, &config.LiveInterval); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// Validate checks that all required settings are present.
+func (c *Config) Validate() error {
+	if c.PrivateKeySecret == "" {
+		return errors.New(envPrivateKeySecret + " not set")
+	}
+	if c.AppID <= 0 {
+		return errors.New(envGHAppID + " not set")
+	}
+	if c.FaultErrorRate < 0 || c.FaultErrorRate > 1 {
+		return errors.New(envFaultErrorRate + " must be between 0 and 1")
+	}
+	if c.FaultLatency < 0 {
+		return errors.New(envFaultLatency + " must not be negative")
+	}
+	if c.LiveInterval < coalesceWindow {
+		return fmt.Errorf("%s must be at least %s", envLiveInterval, coalesceWindow)
+	}
+	for _, entry := range c.APIKeyHashes {
+		scope, hash := splitAPIKeyHash(entry)
+		if decoded, err := hex.DecodeString(hash); err != nil || len(decoded) != sha256.Size {
+			return errors.New(envAPIKeyHashes + " must hold hex SHA-256 hashes")
+		}
+		if !validKeyScope(scope) {
+			return errors.New(envAPIKeyHashes + " entries must be hash, owner=hash or host/owner=hash")
+		}
+	}
+	if _, err := parseNets(c.AllowedIPs); err != nil {
+		return fmt.Errorf("invalid %s: %w", envAllowedIPs, err)
+	}
+	if _, err := parseNets(c.TrustedProxies); err != nil {
+		return fmt.Errorf("invalid %s: %w", envTrustedProxies, err)
+	}
+	if _, err := compileRedactions(c.RedactDefaults, c.RedactPatterns); err != nil {
+		return fmt.Errorf("invalid %s: %w", envRedactPatterns, err)
+	}
+	if c.MaxSubjectLen < 0 || c.MaxStatusLen < 0 {
+		return errors.New("length limits must not be negative")
+	}
+	switch c.UnsafeText {
+	case unsafeTextAllow, unsafeTextFlag, unsafeTextStrip, unsafeTextReject:
+	default:
+		return errors.New(envUnsafeText + " must be allow, flag, strip or reject")
+	}
+	if u, err := url.Parse(c.SonarURL); err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return errors.New(envSonarURL + " must be an absolute URL")
+	}
+	if _, ok := renderers[c.Renderer]; !ok {
+		return errors.New(envRenderer + " must be native, badgen or shields")
+	}
+	if _, ok := palettes[strings.ToLower(c.Palette)]; !ok {
+		return errors.New(envPalette + " must be default, pastel, high-contrast or colorblind-safe")
+	}
+	if c.RendererURL != "" {
+		if u, err := url.Parse(c.RendererURL); err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			return errors.New(envRendererURL + " must be an absolute URL")
+		}
+	}
+	for _, probeURL := range c.ProbeURLs {
+		if u, err := url.Parse(probeURL); err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			return errors.New(envProbeURLs + " must hold absolute URLs")
+		}
+	}
+	if _, err := parseEnterpriseHosts(c.EnterpriseHosts); err != nil {
+		return fmt.Errorf("invalid %s: %w", envEnterpriseHosts, err)
+	}
+	for _, feature := range c.Features {
+		if !validFeature(feature) {
+			return errors.New(envFeatures + " must list " + strings.Join(knownFeatures, ", "))
+		}
+	}
+	if c.faultsEnabled() && c.Mode == modeProduction {
+		return errors.New("fault injection is not allowed in production mode")
+	}
+	return nil
+}
+
+// faultsEnabled reports whether any fault injection is configured.
+func (c *Config) faultsEnabled() bool {
+	return c.FaultErrorRate > 0 || c.FaultLatency > 0
+}
+
+// Redacted returns a copy of the config with secret values replaced.
+func (c *Config) Redacted() *Config {
+	redacted := *c
+	v := reflect.ValueOf(&redacted).Elem()
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := v.Field(i)
+		if t.Field(i).Tag.Get("secret") != "true" {
+			continue
+		}
+		switch {
+		case field.Kind() == reflect.String && field.String() != "":
+			field.SetString(redactedValue)
+		case field.Kind() == reflect.Slice && field.Len() > 0:
+			// The slice is shared with c, so it is replaced rather than changed.
+			list := make([]string, field.Len())
+			for j := range list {
+				list[j] = redactedValue
+			}
+			field.Set(reflect.ValueOf(list))
+		}
+	}
+	return &redacted
+}
+
+// envList reads a comma-separated list.
+func envList(name string) []string {
+	var list []string
+	for _, item := range strings.Split(os.Getenv(name), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// envLines reads a newline-separated list, for items that may contain commas.
+func envLines(name string) []string {
+	var list []string
+	for _, item := range strings.Split(os.Getenv(name), "\n") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+func envBool(name string, dst *bool) error {
+	value := os.Getenv(name)
+	if value == "" {
+		return nil
+	}
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		return fmt.Errorf("invalid %s: %w", name, err)
+	}
+	*dst = parsed
+	return nil
+}
+
+func envInt(name string, dst *int) error {
+	value := os.Getenv(name)
+	if value == "" {
+		return nil
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		return fmt.Errorf("invalid %s: %w", name, err)
+	}
+	*dst = parsed
+	return nil
+}
+
+func envFloat(name string, dst *float64) error {
+	value := os.Getenv(name)
+	if value == "" {
+		return nil
+	}
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return fmt.Errorf("invalid %s: %w", name, err)
+	}
+	*dst = parsed
+	return nil
+}
+
+func envDuration(name string, dst *time.Duration) error {
+	value := os.Getenv(name)
+	if value == "" {
+		return nil
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		return fmt.Errorf("invalid %s: %w", name, err)
+	}
+	*dst = parsed
+	return nil
+}
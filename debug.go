@@ -0,0 +1,41 @@
+package badge
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+)
+
+// debugState is the response of DebugHTTP.
+type debugState struct {
+	Upstream bool `json:"upstream"`
+}
+
+// DebugHTTP is a HTTP cloud function toggling debug logging at runtime.
+// POST with upstream=true or upstream=false switches upstream request
+// logging, GET reports it. The switch only applies to the instance serving
+// the request, until it restarts with AB_DEBUG_UPSTREAM again.
+// It requires an API key not limited to owners and the opt-in debug feature.
+func DebugHTTP(w http.ResponseWriter, r *http.Request) {
+	setup()
+	if !requireFeature(w, featureDebug) || !requireAPIKey(w, r, "", "") {
+		return
+	}
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+	case http.MethodPost:
+		on, err := strconv.ParseBool(r.FormValue("upstream"))
+		if err != nil {
+			http.Error(w, "Invalid upstream", http.StatusBadRequest)
+			return
+		}
+		setDebugUpstream(on)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(debugState{Upstream: atomic.LoadInt32(&debugUpstream) != 0})
+}
@@ -0,0 +1,84 @@
+package badge
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/bradleyfalzon/ghinstallation"
+	"github.com/google/go-github/v37/github"
+)
+
+// DiagnosticResult is the outcome of one self-diagnostic check.
+type DiagnosticResult struct {
+	Check  string
+	Detail string
+	Err    error
+}
+
+// Diagnose runs the startup checks in order and stops at the first failure:
+// config, credentials, App JWT, installation token and, if sample is not nil,
+// an end-to-end resolution of the badge described by sample.
+// It installs the config and credentials as if the function had started.
+func Diagnose(ctx context.Context, sample url.Values) []DiagnosticResult {
+	var results []DiagnosticResult
+	check := func(name, detail string, err error) bool {
+		results = append(results, DiagnosticResult{Check: name, Detail: detail, Err: err})
+		return err == nil
+	}
+	// Config.
+	cfg, err := LoadConfig()
+	if err == nil {
+		err = cfg.Validate()
+	}
+	if err == nil {
+		err = applyConfig(cfg)
+	}
+	if !check("config", "", err) {
+		return results
+	}
+	// Credentials, installed the way setup does.
+	for _, step := range credentialSteps(cfg) {
+		if !check(step.name, step.detail, step.run()) {
+			return results
+		}
+	}
+	// App JWT.
+	appClient := github.NewClient(&http.Client{Transport: appsTransport})
+	app, _, err := appClient.Apps.Get(ctx, "")
+	if !check("app jwt", app.GetSlug(), err) {
+		return results
+	}
+	// Installation token.
+	installations, _, err := appClient.Apps.ListInstallations(ctx, &github.ListOptions{PerPage: 1})
+	if err == nil && len(installations) == 0 {
+		err = errors.New("app has no installations")
+	}
+	if !check("installation", "", err) {
+		return results
+	}
+	installation := installations[0]
+	_, err = ghinstallation.NewFromAppsTransport(appsTransport, installation.GetID()).Token(ctx)
+	if !check("installation token", installation.GetAccount().GetLogin(), err) {
+		return results
+	}
+	if sample == nil {
+		return results
+	}
+	// Sample badge.
+	req := &http.Request{Form: sample}
+	query, err := parseBadgeQuery(req)
+	if !check("sample query", sample.Encode(), err) {
+		return results
+	}
+	query.allowPrivate = true
+	res, err := resolve(ctx, query)
+	if err != nil {
+		check("sample badge", "", err)
+		return results
+	}
+	check("sample badge", fmt.Sprintf("artifact %d: %q", res.ArtifactID, res.Status), nil)
+	return results
+}
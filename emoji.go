@@ -0,0 +1,53 @@
+package badge
+
+import "unicode"
+
+// emojiWidth is the advance of an emoji at badgeFontSize, as drawn
+// by the color emoji fonts browsers fall back to.
+const emojiWidth = 1.25 * badgeFontSize
+
+// isEmojiRune reports whether r is drawn as an emoji.
+func isEmojiRune(r rune) bool {
+	return r >= 0x1F000 && r <= 0x1FAFF ||
+		r >= 0x2600 && r <= 0x27BF ||
+		r >= 0x2B00 && r <= 0x2BFF
+}
+
+// isRegionalIndicator reports whether r is one half of a flag emoji.
+func isRegionalIndicator(r rune) bool {
+	return r >= 0x1F1E6 && r <= 0x1F1FF
+}
+
+// isZeroWidthRune reports whether r modifies the previous character
+// instead of taking up space of its own.
+func isZeroWidthRune(r rune) bool {
+	return r == '\u200d' || // zero width joiner
+		r >= 0xFE00 && r <= 0xFE0F || // variation selectors
+		r >= 0x1F3FB && r <= 0x1F3FF || // skin tone modifiers
+		r >= 0xE0020 && r <= 0xE007F || // tag sequences
+		unicode.Is(unicode.Mn, r)
+}
+
+// visibleRunes returns the characters of text that take up space,
+// counting emoji sequences joined by ZWJ and flag pairs once.
+func visibleRunes(text string) []rune {
+	runes := make([]rune, 0, len(text))
+	var joined, flag bool
+	for _, r := range text {
+		switch {
+		case r == '\u200d':
+			joined = true
+			continue
+		case isZeroWidthRune(r):
+			continue
+		case joined:
+		case isRegionalIndicator(r) && flag:
+			flag = false
+		default:
+			runes = append(runes, r)
+			flag = isRegionalIndicator(r)
+		}
+		joined = false
+	}
+	return runes
+}
@@ -0,0 +1,27 @@
+package badge
+
+import "encoding/json"
+
+const jsonContentType = "application/json"
+
+// endpointBadge is the shields.io endpoint badge schema.
+// See https://shields.io/endpoint
+type endpointBadge struct {
+	SchemaVersion int    `json:"schemaVersion"`
+	Label         string `json:"label"`
+	Message       string `json:"message"`
+	Color         string `json:"color,omitempty"`
+	NamedLogo     string `json:"namedLogo,omitempty"`
+}
+
+// EndpointJSON renders the badge as a shields.io endpoint badge.
+func (b *Badge) EndpointJSON() ([]byte, error) {
+	subject, status := b.flatTexts()
+	return json.Marshal(&endpointBadge{
+		SchemaVersion: 1,
+		Label:         subject,
+		Message:       status,
+		Color:         firstColor(b.Color),
+		NamedLogo:     b.Icon,
+	})
+}
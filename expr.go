@@ -0,0 +1,255 @@
+package badge
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// maxExprLen limits the length of value expressions.
+const maxExprLen = 256
+
+// exprFuncs are the functions available to value expressions.
+var exprFuncs = map[string]func(args []float64) (float64, error){
+	"round": func(args []float64) (float64, error) {
+		switch len(args) {
+		case 1:
+			return math.Round(args[0]), nil
+		case 2:
+			scale := math.Pow(10, math.Round(args[1]))
+			return math.Round(args[0]*scale) / scale, nil
+		}
+		return 0, errors.New("round takes 1 or 2 arguments")
+	},
+	"floor": unaryExprFunc("floor", math.Floor),
+	"ceil":  unaryExprFunc("ceil", math.Ceil),
+	"abs":   unaryExprFunc("abs", math.Abs),
+	"sqrt":  unaryExprFunc("sqrt", math.Sqrt),
+	"min": func(args []float64) (float64, error) {
+		if len(args) == 0 {
+			return 0, errors.New("min takes at least 1 argument")
+		}
+		m := args[0]
+		for _, arg := range args[1:] {
+			m = math.Min(m, arg)
+		}
+		return m, nil
+	},
+	"max": func(args []float64) (float64, error) {
+		if len(args) == 0 {
+			return 0, errors.New("max takes at least 1 argument")
+		}
+		m := args[0]
+		for _, arg := range args[1:] {
+			m = math.Max(m, arg)
+		}
+		return m, nil
+	},
+	"pow": func(args []float64) (float64, error) {
+		if len(args) != 2 {
+			return 0, errors.New("pow takes 2 arguments")
+		}
+		return math.Pow(args[0], args[1]), nil
+	},
+}
+
+func unaryExprFunc(name string, fn func(float64) float64) func(args []float64) (float64, error) {
+	return func(args []float64) (float64, error) {
+		if len(args) != 1 {
+			return 0, fmt.Errorf("%s takes 1 argument", name)
+		}
+		return fn(args[0]), nil
+	}
+}
+
+// evalExpr evaluates an arithmetic expression over a numeric value,
+// which the expression refers to as "value".
+func evalExpr(expr string, value float64) (float64, error) {
+	if len(expr) > maxExprLen {
+		return 0, errors.New("expression too long")
+	}
+	p := &exprParser{src: expr, value: value}
+	result, err := p.parseSum()
+	if err != nil {
+		return 0, err
+	}
+	p.skipSpace()
+	if p.pos < len(p.src) {
+		return 0, fmt.Errorf("unexpected %q at %d", p.src[p.pos], p.pos)
+	}
+	if math.IsNaN(result) || math.IsInf(result, 0) {
+		return 0, errors.New("result is not a finite number")
+	}
+	return result, nil
+}
+
+// formatNumber formats a number without trailing zeros.
+func formatNumber(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// exprParser is a recursive descent parser evaluating while parsing.
+//
+//	sum     = product { ("+" | "-") product }
+//	product = unary { ("*" | "/" | "%") unary }
+//	unary   = "-" unary | primary
+//	primary = number | "value" | ident "(" [ sum { "," sum } ] ")" | "(" sum ")"
+type exprParser struct {
+	src   string
+	pos   int
+	value float64
+}
+
+func (p *exprParser) skipSpace() {
+	for p.pos < len(p.src) && p.src[p.pos] == ' ' {
+		p.pos++
+	}
+}
+
+// accept consumes c if it is the next non-space character.
+func (p *exprParser) accept(c byte) bool {
+	p.skipSpace()
+	if p.pos < len(p.src) && p.src[p.pos] == c {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *exprParser) parseSum() (float64, error) {
+	left, err := p.parseProduct()
+	if err != nil {
+		return 0, err
+	}
+	for {
+		switch {
+		case p.accept('+'):
+			right, err := p.parseProduct()
+			if err != nil {
+				return 0, err
+			}
+			left += right
+		case p.accept('-'):
+			right, err := p.parseProduct()
+			if err != nil {
+				return 0, err
+			}
+			left -= right
+		default:
+			return left, nil
+		}
+	}
+}
+
+func (p *exprParser) parseProduct() (float64, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return 0, err
+	}
+	for {
+		switch {
+		case p.accept('*'):
+			right, err := p.parseUnary()
+			if err != nil {
+				return 0, err
+			}
+			left *= right
+		case p.accept('/'):
+			right, err := p.parseUnary()
+			if err != nil {
+				return 0, err
+			}
+			if right == 0 {
+				return 0, errors.New("division by zero")
+			}
+			left /= right
+		case p.accept('%'):
+			right, err := p.parseUnary()
+			if err != nil {
+				return 0, err
+			}
+			if right == 0 {
+				return 0, errors.New("division by zero")
+			}
+			left = math.Mod(left, right)
+		default:
+			return left, nil
+		}
+	}
+}
+
+func (p *exprParser) parseUnary() (float64, error) {
+	if p.accept('-') {
+		v, err := p.parseUnary()
+		return -v, err
+	}
+	return p.parsePrimary()
+}
+
+func (p *exprParser) parsePrimary() (float64, error) {
+	if p.accept('(') {
+		v, err := p.parseSum()
+		if err != nil {
+			return 0, err
+		}
+		if !p.accept(')') {
+			return 0, errors.New("missing )")
+		}
+		return v, nil
+	}
+	p.skipSpace()
+	start := p.pos
+	for p.pos < len(p.src) && (isExprDigit(p.src[p.pos]) || p.src[p.pos] == '.') {
+		p.pos++
+	}
+	if p.pos > start {
+		return strconv.ParseFloat(p.src[start:p.pos], 64)
+	}
+	for p.pos < len(p.src) && isExprLetter(p.src[p.pos]) {
+		p.pos++
+	}
+	ident := strings.ToLower(p.src[start:p.pos])
+	if ident == "" {
+		if p.pos < len(p.src) {
+			return 0, fmt.Errorf("unexpected %q at %d", p.src[p.pos], p.pos)
+		}
+		return 0, errors.New("unexpected end of expression")
+	}
+	if ident == "value" {
+		return p.value, nil
+	}
+	fn, ok := exprFuncs[ident]
+	if !ok {
+		return 0, fmt.Errorf("unknown name %q", ident)
+	}
+	if !p.accept('(') {
+		return 0, fmt.Errorf("missing ( after %s", ident)
+	}
+	var args []float64
+	if !p.accept(')') {
+		for {
+			arg, err := p.parseSum()
+			if err != nil {
+				return 0, err
+			}
+			args = append(args, arg)
+			if p.accept(')') {
+				break
+			}
+			if !p.accept(',') {
+				return 0, errors.New("missing , or )")
+			}
+		}
+	}
+	return fn(args)
+}
+
+func isExprDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isExprLetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_'
+}
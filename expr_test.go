@@ -0,0 +1,87 @@
+package badge
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestEvalExpr(t *testing.T) {
+	tests := []struct {
+		expr  string
+		value float64
+		want  float64
+	}{
+		{"value", 42, 42},
+		{"1 + 2 * 3", 0, 7},
+		{"(1 + 2) * 3", 0, 9},
+		{"10 - 4 - 3", 0, 3},
+		{"24 / 4 / 2", 0, 3},
+		{"7 % 4 * 2", 0, 6},
+		{"2 * 3 % 4", 0, 2},
+		{"-value", 5, -5},
+		{"--value", 5, 5},
+		{"-2 * -3", 0, 6},
+		{"1 - -1", 0, 2},
+		{"-(1 + 2) * 2", 0, -6},
+		{"value / 1024 / 1024", 3 << 20, 3},
+		{"value * 100", 0.873, 87.3},
+		{"round(value * 100, 1)", 0.8734, 87.3},
+		{"round(2.5)", 0, 3},
+		{"floor(-1.5) + ceil(1.2)", 0, 0},
+		{"abs(-3) + sqrt(16)", 0, 7},
+		{"min(3, value, 1) + max(value)", 2, 3},
+		{"pow(2, 10)", 0, 1024},
+		{"ROUND(Value)", 1.4, 1},
+		{"  1+2  ", 0, 3},
+		{strings.Repeat("(", 125) + "value" + strings.Repeat(")", 125), 8, 8},
+		{strings.Repeat("-", maxExprLen-1) + "1", 0, -1},
+	}
+	for _, tt := range tests {
+		got, err := evalExpr(tt.expr, tt.value)
+		if err != nil || got != tt.want {
+			t.Errorf("%.40s with value %v: got %v, %v, want %v", tt.expr, tt.value, got, err, tt.want)
+		}
+	}
+}
+
+func TestEvalExprErrors(t *testing.T) {
+	tests := []struct {
+		expr  string
+		value float64
+	}{
+		{"", 0},
+		{"1 +", 0},
+		{"1 2", 0},
+		{"(1 + 2", 0},
+		{"1 + 2)", 0},
+		{"()", 0},
+		{"1..2", 0},
+		{"value(1)", 0},
+		{"unknown(1)", 0},
+		{"round", 0},
+		{"round()", 0},
+		{"round(1, 2, 3)", 0},
+		{"min()", 0},
+		{"pow(2)", 0},
+		{"max(1,)", 0},
+		{"1 / 0", 0},
+		{"1 % 0", 0},
+		{"1 / value", 0},
+		{"1 / (value - value)", 3},
+		{"sqrt(-1)", 0},
+		{"value * 0", math.Inf(1)},
+		{"value", math.NaN()},
+		{"pow(10, 400)", 0},
+		{"-pow(10, 400)", 0},
+		{"pow(10, 400) - pow(10, 400)", 0},
+		{"value * 10", 1e308},
+		{strings.Repeat("(", 126) + "value" + strings.Repeat(")", 126), 0},
+		{strings.Repeat(" ", maxExprLen) + "1", 0},
+	}
+	for _, tt := range tests {
+		if got, err := evalExpr(tt.expr, tt.value); err == nil {
+			t.Errorf("%.40s with value %v: got %v, want error", tt.expr, tt.value, got)
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package badge
+
+import (
+	"errors"
+	"math/rand"
+	"net/http"
+	"time"
+)
+
+// errInjectedFault is returned by upstream requests failed on purpose.
+var errInjectedFault = errors.New("injected fault")
+
+// faultTransport delays and fails upstream requests for resilience testing.
+type faultTransport struct {
+	next      http.RoundTripper
+	errorRate float64
+	latency   time.Duration
+}
+
+func (t *faultTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.latency > 0 {
+		timer := time.NewTimer(t.latency)
+		select {
+		case <-timer.C:
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		}
+	}
+	if t.errorRate > 0 && rand.Float64() < t.errorRate {
+		return nil, errInjectedFault
+	}
+	return t.next.RoundTrip(req)
+}
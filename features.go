@@ -0,0 +1,75 @@
+package badge
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Optional features that can be turned off per deployment.
+const (
+	featureSources     = "sources"
+	featurePrivate     = "private"
+	featureOnboard     = "onboard"
+	featureBranches    = "branches"
+	featureStatus      = "status"
+	featureResolve     = "resolve"
+	featureSprites     = "sprites"
+	featureSocial      = "social"
+	featureLive        = "live"
+	featureVanity      = "vanity"
+	featureDynamicJSON = "dynamic-json"
+	featureStatic      = "static"
+	featureDebug       = "debug"
+)
+
+// knownFeatures lists all optional features, which are enabled by default
+// unless they are opt-in.
+var knownFeatures = []string{
+	featureSources, featurePrivate, featureOnboard, featureBranches,
+	featureStatus, featureResolve, featureSprites, featureSocial, featureLive,
+	featureVanity, featureDynamicJSON, featureStatic, featureDebug,
+}
+
+// optInFeatures are admin features only enabled if listed in the config.
+var optInFeatures = map[string]bool{
+	featureDebug: true,
+}
+
+// enabledFeatures holds the features enabled by the config.
+var enabledFeatures = parseFeatures(nil)
+
+// parseFeatures returns the set of enabled features,
+// all but the opt-in ones if the list is empty.
+func parseFeatures(list []string) map[string]bool {
+	if len(list) == 0 {
+		for _, feature := range knownFeatures {
+			if !optInFeatures[feature] {
+				list = append(list, feature)
+			}
+		}
+	}
+	features := make(map[string]bool, len(list))
+	for _, feature := range list {
+		features[strings.ToLower(feature)] = true
+	}
+	return features
+}
+
+// validFeature reports whether feature names an optional feature.
+func validFeature(feature string) bool {
+	for _, known := range knownFeatures {
+		if strings.EqualFold(feature, known) {
+			return true
+		}
+	}
+	return false
+}
+
+// requireFeature fails the request unless feature is enabled.
+func requireFeature(w http.ResponseWriter, feature string) bool {
+	if !enabledFeatures[feature] {
+		http.Error(w, "Feature disabled", http.StatusNotFound)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,487 @@
+package badge
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+	tmplparse "text/template/parse"
+	"time"
+)
+
+// Limits of user supplied status templates.
+const (
+	maxTemplateLen    = 512
+	maxTemplateOutput = 1024
+)
+
+// maxPrecision caps the decimal places of the precision param.
+const maxPrecision = 10
+
+// defaultEmptyText is the status of badges whose artifact holds no value.
+const defaultEmptyText = "null"
+
+// Status and color of badges whose artifact holds no value, set by the config.
+var (
+	emptyText  = defaultEmptyText
+	emptyColor string
+)
+
+// errTemplateOutput is returned for templates producing too much text.
+var errTemplateOutput = errors.New("template output too long")
+
+// templateData is what status templates can access.
+type templateData struct {
+	Value      string
+	Subject    string
+	Repo       string
+	Branch     string
+	Workflow   string
+	RunID      int64
+	RunNumber  int
+	RunURL     string
+	SHA        string
+	ShortSHA   string
+	Event      string
+	RunAt      time.Time
+	ResolvedAt time.Time
+}
+
+// templateFuncs is the function set available to status templates.
+// It has no access to the environment, network or file system.
+var templateFuncs = template.FuncMap{
+	"upper":      strings.ToUpper,
+	"lower":      strings.ToLower,
+	"title":      strings.Title,
+	"trim":       strings.TrimSpace,
+	"trimPrefix": func(prefix, s string) string { return strings.TrimPrefix(s, prefix) },
+	"trimSuffix": func(suffix, s string) string { return strings.TrimSuffix(s, suffix) },
+	"replace": func(old, new, s string) (string, error) {
+		// Nested replacements could otherwise grow text exponentially.
+		if n := strings.Count(s, old); len(s)+n*(len(new)-len(old)) > maxTemplateOutput {
+			return "", errTemplateOutput
+		}
+		return strings.Replace(s, old, new, -1), nil
+	},
+	"default": func(def, s string) string {
+		if s == "" {
+			return def
+		}
+		return s
+	},
+	"float": func(s string) (float64, error) { return strconv.ParseFloat(strings.TrimSpace(s), 64) },
+	"round": func(places int, f float64) float64 {
+		scale := math.Pow(10, float64(places))
+		return math.Round(f*scale) / scale
+	},
+	// The builtins building text are replaced by size checked versions.
+	"printf": func(format string, args ...interface{}) (string, error) {
+		if err := checkFormatWidths(format); err != nil {
+			return "", err
+		}
+		return checkTemplateText(fmt.Sprintf(format, args...))
+	},
+	"print":   func(args ...interface{}) (string, error) { return checkTemplateText(fmt.Sprint(args...)) },
+	"println": func(args ...interface{}) (string, error) { return checkTemplateText(fmt.Sprintln(args...)) },
+	"len":     func(s string) int { return len(s) },
+}
+
+// templateBuiltins are the builtins of text/template allowed in status
+// templates besides templateFuncs. Their results are no larger than their
+// arguments.
+var templateBuiltins = map[string]bool{
+	"and": true, "or": true, "not": true,
+	"eq": true, "ne": true, "lt": true, "le": true, "gt": true, "ge": true,
+}
+
+// checkTemplateText fails for text longer than the template output limit.
+func checkTemplateText(s string) (string, error) {
+	if len(s) > maxTemplateOutput {
+		return "", errTemplateOutput
+	}
+	return s, nil
+}
+
+// checkFormatWidths rejects printf formats whose widths or precisions
+// would pad a value beyond the template output limit before it is checked.
+func checkFormatWidths(format string) error {
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			continue
+		}
+		for i++; i < len(format); i++ {
+			c := format[i]
+			if c == '*' {
+				return errors.New("printf: * width is not allowed")
+			}
+			if c >= '1' && c <= '9' {
+				n := 0
+				for ; i < len(format) && format[i] >= '0' && format[i] <= '9'; i++ {
+					if n = n*10 + int(format[i]-'0'); n > maxTemplateOutput {
+						return errTemplateOutput
+					}
+				}
+				i--
+				continue
+			}
+			if strings.IndexByte("+-# 0.[]", c) < 0 {
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// formatStatus turns a resolved value into the displayed status
+// according to the formatting params of a request.
+func formatStatus(r *http.Request, subject string, query *badgeQuery, res *resolution) (string, error) {
+	status := res.Status
+	mapped, ok, err := mapValue(r.FormValue("map"), status)
+	if err != nil {
+		return "", errors.New("Invalid map: " + err.Error())
+	}
+	if ok {
+		status = mapped
+	} else if status, err = formatValue(r, status); err != nil {
+		return "", err
+	}
+	switch r.FormValue("case") {
+	case "":
+	case "upper":
+		status = strings.ToUpper(status)
+	case "lower":
+		status = strings.ToLower(status)
+	case "title":
+		status = strings.Title(status)
+	default:
+		return "", errors.New("Unknown case")
+	}
+	status = r.FormValue("prefix") + status + r.FormValue("suffix")
+	if text := r.FormValue("template"); text != "" {
+		status, err = executeStatusTemplate(text, newTemplateData(status, subject, query, res))
+		if err != nil {
+			return "", errors.New("Invalid template: " + err.Error())
+		}
+	}
+	return status, nil
+}
+
+// formatValue applies the numeric formatting params of a request to a value.
+func formatValue(r *http.Request, status string) (string, error) {
+	if expr := r.FormValue("expr"); expr != "" {
+		value, err := strconv.ParseFloat(strings.TrimSpace(status), 64)
+		if err != nil {
+			return "", errors.New("Value is not a number")
+		}
+		value, err = evalExpr(expr, value)
+		if err != nil {
+			return "", errors.New("Invalid expr: " + err.Error())
+		}
+		status = formatNumber(value)
+	}
+	if r.FormValue("min") != "" || r.FormValue("max") != "" {
+		value, err := strconv.ParseFloat(strings.TrimSpace(status), 64)
+		if err != nil {
+			return "", errors.New("Value is not a number")
+		}
+		value, err = clampParams(r, value)
+		if err != nil {
+			return "", err
+		}
+		status = formatNumber(value)
+	}
+	if param := r.FormValue("precision"); param != "" {
+		places, err := strconv.Atoi(param)
+		if err != nil || places < 0 || places > maxPrecision {
+			return "", errors.New("Invalid precision")
+		}
+		value, err := strconv.ParseFloat(strings.TrimSpace(status), 64)
+		if err != nil {
+			return "", errors.New("Value is not a number")
+		}
+		status = strconv.FormatFloat(value, 'f', places, 64)
+	}
+	if format := r.FormValue("numfmt"); format != "" {
+		value, err := strconv.ParseFloat(strings.TrimSpace(status), 64)
+		if err != nil {
+			return "", errors.New("Value is not a number")
+		}
+		status, err = formatNumeric(value, format)
+		if err != nil {
+			return "", err
+		}
+	}
+	if unit := r.FormValue("unit"); unit != "" {
+		if r.FormValue("numfmt") != "" {
+			return "", errors.New("Set either numfmt or unit")
+		}
+		value, err := strconv.ParseFloat(strings.TrimSpace(status), 64)
+		if err != nil {
+			return "", errors.New("Value is not a number")
+		}
+		status, err = formatUnit(value, unit)
+		if err != nil {
+			return "", err
+		}
+	}
+	return status, nil
+}
+
+// mapValue looks up a value in a map spec like 0:passing,1:failing,
+// where the key * matches any value. It reports whether the value matched.
+func mapValue(spec, value string) (string, bool, error) {
+	if spec == "" {
+		return "", false, nil
+	}
+	value = strings.TrimSpace(value)
+	var def string
+	var hasDef bool
+	for _, entry := range strings.Split(spec, ",") {
+		sep := strings.IndexByte(entry, ':')
+		if sep < 0 {
+			return "", false, fmt.Errorf("entry %q without :", entry)
+		}
+		key, text := strings.TrimSpace(entry[:sep]), entry[sep+1:]
+		if key == value {
+			return text, true, nil
+		}
+		if key == "*" && !hasDef {
+			def, hasDef = text, true
+		}
+	}
+	return def, hasDef, nil
+}
+
+// displayStatus returns the status and color shown for a resolved value.
+func displayStatus(r *http.Request, subject string, query *badgeQuery, res *resolution) (string, string, error) {
+	switch {
+	case res.Running:
+		return res.Status, runningColor, nil
+	case res.Empty:
+		status, color := emptyStatus(r, res)
+		if color != "" {
+			return status, color, nil
+		}
+		color, err := statusColor(r, res.Status, status)
+		return status, color, err
+	}
+	status, err := formatStatus(r, subject, query, res)
+	if err != nil {
+		return "", "", err
+	}
+	color, err := statusColor(r, res.Status, status)
+	return status, color, err
+}
+
+// emptyStatus returns the status and color of a badge without a value,
+// taken from the fallback and fallbackColor params or the config.
+// The color is empty if the usual color rules apply.
+func emptyStatus(r *http.Request, res *resolution) (string, string) {
+	status, color := r.FormValue("fallback"), r.FormValue("fallbackColor")
+	if status == "" {
+		status = res.Status
+	}
+	if color == "" {
+		color = emptyColor
+	}
+	return status, color
+}
+
+// clampParams limits a value to the bounds given by the min and max params.
+func clampParams(r *http.Request, value float64) (float64, error) {
+	lo, hi := math.Inf(-1), math.Inf(1)
+	var err error
+	if param := r.FormValue("min"); param != "" {
+		if lo, err = strconv.ParseFloat(param, 64); err != nil || math.IsNaN(lo) {
+			return 0, errors.New("Invalid min")
+		}
+	}
+	if param := r.FormValue("max"); param != "" {
+		if hi, err = strconv.ParseFloat(param, 64); err != nil || math.IsNaN(hi) {
+			return 0, errors.New("Invalid max")
+		}
+	}
+	if lo > hi {
+		return 0, errors.New("Min is above max")
+	}
+	return math.Max(lo, math.Min(hi, value)), nil
+}
+
+func newTemplateData(value, subject string, query *badgeQuery, res *resolution) *templateData {
+	data := &templateData{
+		Value:      value,
+		Subject:    subject,
+		Repo:       query.Owner + "/" + query.Repo,
+		Branch:     query.Branch,
+		Workflow:   query.Run,
+		ResolvedAt: res.ResolvedAt,
+	}
+	if run := res.Run; run != nil {
+		data.Branch = run.GetHeadBranch()
+		data.Workflow = run.GetName()
+		data.RunID = run.GetID()
+		data.RunNumber = run.GetRunNumber()
+		data.RunURL = run.GetHTMLURL()
+		data.SHA = run.GetHeadSHA()
+		data.ShortSHA = data.SHA
+		if len(data.ShortSHA) > 7 {
+			data.ShortSHA = data.ShortSHA[:7]
+		}
+		data.Event = run.GetEvent()
+		data.RunAt = run.GetCreatedAt().Time
+	}
+	return data
+}
+
+// executeStatusTemplate renders a status template with bounded size.
+func executeStatusTemplate(text string, data *templateData) (string, error) {
+	if len(text) > maxTemplateLen {
+		return "", errors.New("template too long")
+	}
+	tmpl, err := template.New("status").Funcs(templateFuncs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", err
+	}
+	if len(tmpl.Templates()) > 1 {
+		return "", errors.New("define is not allowed")
+	}
+	if err := checkTemplateNode(tmpl.Tree.Root); err != nil {
+		return "", err
+	}
+	out := &limitedBuffer{max: maxTemplateOutput}
+	if err := tmpl.Execute(out, data); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(out.String()), nil
+}
+
+// checkTemplateNode rejects loops, template calls, variables and functions
+// outside of the allowlist, so that the run time and memory use of status
+// templates is bounded by their length.
+func checkTemplateNode(node tmplparse.Node) error {
+	switch node := node.(type) {
+	case *tmplparse.ListNode:
+		if node == nil {
+			return nil
+		}
+		for _, child := range node.Nodes {
+			if err := checkTemplateNode(child); err != nil {
+				return err
+			}
+		}
+	case *tmplparse.ActionNode:
+		return checkTemplateNode(node.Pipe)
+	case *tmplparse.IfNode:
+		if err := checkTemplateNode(node.Pipe); err != nil {
+			return err
+		}
+		if err := checkTemplateNode(node.List); err != nil {
+			return err
+		}
+		return checkTemplateNode(node.ElseList)
+	case *tmplparse.PipeNode:
+		if node == nil {
+			return nil
+		}
+		if len(node.Decl) > 0 {
+			return errors.New("variables are not allowed")
+		}
+		for _, cmd := range node.Cmds {
+			if err := checkTemplateNode(cmd); err != nil {
+				return err
+			}
+		}
+	case *tmplparse.CommandNode:
+		for _, arg := range node.Args {
+			if err := checkTemplateNode(arg); err != nil {
+				return err
+			}
+		}
+	case *tmplparse.ChainNode:
+		return checkTemplateNode(node.Node)
+	case *tmplparse.IdentifierNode:
+		if _, ok := templateFuncs[node.Ident]; !ok && !templateBuiltins[node.Ident] {
+			return fmt.Errorf("function %s is not allowed", node.Ident)
+		}
+	case *tmplparse.RangeNode:
+		return errors.New("range is not allowed")
+	case *tmplparse.WithNode:
+		return errors.New("with is not allowed")
+	case *tmplparse.TemplateNode:
+		return errors.New("template is not allowed")
+	}
+	return nil
+}
+
+// limitedBuffer is a buffer failing writes beyond max bytes.
+type limitedBuffer struct {
+	bytes.Buffer
+	max int
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if b.Len()+len(p) > b.max {
+		return 0, errTemplateOutput
+	}
+	return b.Buffer.Write(p)
+}
+
+// placeholder matches {name} placeholders in subjects and labels.
+var placeholder = regexp.MustCompile(`\{(\w+)\}`)
+
+// placeholderFields are the run fields filling {name} placeholders,
+// by lowercase name.
+var placeholderFields = map[string]func(data *templateData) string{
+	"value":    func(data *templateData) string { return data.Value },
+	"status":   func(data *templateData) string { return data.Value },
+	"subject":  func(data *templateData) string { return data.Subject },
+	"repo":     func(data *templateData) string { return data.Repo },
+	"branch":   func(data *templateData) string { return data.Branch },
+	"workflow": func(data *templateData) string { return data.Workflow },
+	"run":      func(data *templateData) string { return data.Workflow },
+	"runid": func(data *templateData) string {
+		if data.RunID == 0 {
+			return ""
+		}
+		return strconv.FormatInt(data.RunID, 10)
+	},
+	"runnumber": runNumberText,
+	"number":    runNumberText,
+	"sha":       func(data *templateData) string { return data.SHA },
+	"shortsha":  func(data *templateData) string { return data.ShortSHA },
+	"event":     func(data *templateData) string { return data.Event },
+}
+
+// runNumberText fills run number placeholders.
+func runNumberText(data *templateData) string {
+	if data.RunNumber == 0 {
+		return ""
+	}
+	return strconv.Itoa(data.RunNumber)
+}
+
+// expandPlaceholders fills {name} placeholders in text from the resolved run.
+// Fields the run left empty fall back to the request param of that name.
+// Other names are kept, so that params such as key and sig can't be
+// echoed into public badges.
+func expandPlaceholders(r *http.Request, text string, data *templateData) string {
+	if !strings.Contains(text, "{") {
+		return text
+	}
+	return placeholder.ReplaceAllStringFunc(text, func(match string) string {
+		name := match[1 : len(match)-1]
+		field, ok := placeholderFields[strings.ToLower(name)]
+		if !ok {
+			return match
+		}
+		if value := field(data); value != "" {
+			return value
+		}
+		return r.FormValue(name)
+	})
+}
@@ -0,0 +1,83 @@
+package badge
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestExecuteStatusTemplate(t *testing.T) {
+	data := &templateData{Value: "87", Branch: "main"}
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"{{.Value}}% on {{.Branch}}", "87% on main"},
+		{`{{if eq .Value "87"}}good{{else}}bad{{end}}`, "good"},
+		{`{{printf "%s!" .Value}}`, "87!"},
+		{`{{printf "%05.1f" (float .Value)}}`, "087.0"},
+		{`{{print .Value .Branch}}`, "87main"},
+		{`{{len .Branch}}`, "4"},
+		{`{{.Value | upper | printf "[%s]"}}`, "[87]"},
+	}
+	for _, tt := range tests {
+		got, err := executeStatusTemplate(tt.text, data)
+		if err != nil || got != tt.want {
+			t.Errorf("%s: got %q, %v, want %q", tt.text, got, err, tt.want)
+		}
+	}
+}
+
+func TestExecuteStatusTemplateBounded(t *testing.T) {
+	data := &templateData{Value: "87"}
+	rejected := []string{
+		// Builds a 64 MB string through variables if not rejected.
+		`{{$a := printf "%0999999d" 0}}{{$b := printf "%s%s%s%s%s%s%s%s" $a $a $a $a $a $a $a $a}}{{$c := printf "%s%s%s%s%s%s%s%s" $b $b $b $b $b $b $b $b}}{{len $c}}`,
+		`{{$v := .Value}}{{$v}}`,
+		`{{if $v := .Value}}{{$v}}{{end}}`,
+		`{{printf "%0999999d" 0}}`,
+		`{{printf "%.999999f" 1.0}}`,
+		`{{printf "%*d" 999999 0}}`,
+		`{{printf "%01025d" 0}}`,
+		`{{printf "%s%s" (printf "%01000d" 0) (printf "%01000d" 0)}}`,
+		`{{print (printf "%01000d" 0) (printf "%01000d" 0)}}`,
+		`{{replace "" (printf "%01000d" 0) .Value}}`,
+		`{{range .Value}}x{{end}}`,
+		`{{with .Value}}{{.}}{{end}}`,
+		`{{define "x"}}{{end}}{{template "x"}}`,
+		`{{index .Value 0}}`,
+		`{{slice .Value 0 1}}`,
+		`{{call .Value}}`,
+		`{{html .Value}}`,
+		"{{.Value}}" + strings.Repeat(" ", maxTemplateLen),
+	}
+	for _, text := range rejected {
+		if got, err := executeStatusTemplate(text, data); err == nil {
+			t.Errorf("%.60s: got %.20q, want error", text, got)
+		}
+	}
+}
+
+func TestExpandPlaceholders(t *testing.T) {
+	data := &templateData{Value: "87", Branch: "main", Workflow: "CI", RunNumber: 12, ShortSHA: "abc1234"}
+	r := httptest.NewRequest("GET", "/?key=secret&sig=deadbeef&repo=org/repo&event=push&runId=99&note=hi", nil)
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"{workflow} on {branch}", "CI on main"},
+		{"#{RunNumber} ({shortSha})", "#12 (abc1234)"},
+		{"{value}%", "87%"},
+		// Empty fields fall back to params.
+		{"{repo} {event} {runId}", "org/repo push 99"},
+		// Other params are never echoed.
+		{"{key} {sig} {note}", "{key} {sig} {note}"},
+		{"{unknown}", "{unknown}"},
+		{"no placeholders", "no placeholders"},
+	}
+	for _, tt := range tests {
+		if got := expandPlaceholders(r, tt.text, data); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package badge
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// trustedProxies are the networks of proxies whose forwarding headers are believed.
+var trustedProxies []*net.IPNet
+
+// forwardedClientIP follows the forwarding headers set by trusted proxies
+// back from the connecting address ip to the first untrusted hop.
+// It returns nil if a hop reported by a trusted proxy is unusable.
+func forwardedClientIP(ip net.IP, header http.Header) net.IP {
+	if !containsIP(trustedProxies, ip) {
+		return ip
+	}
+	hops := forwardedHops(header)
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := hops[i]
+		if hop == nil {
+			return nil
+		}
+		if !containsIP(trustedProxies, hop) {
+			return hop
+		}
+		ip = hop
+	}
+	return ip
+}
+
+// forwardedHops lists the client addresses of the Forwarded header,
+// or of X-Forwarded-For if there is none, nearest hop last.
+// Obfuscated and invalid addresses are nil.
+func forwardedHops(header http.Header) []net.IP {
+	var hops []net.IP
+	if values := header.Values("Forwarded"); len(values) > 0 {
+		for _, value := range values {
+			for _, element := range strings.Split(value, ",") {
+				var hop net.IP
+				for _, pair := range strings.Split(element, ";") {
+					pair = strings.TrimSpace(pair)
+					if len(pair) > 4 && strings.EqualFold(pair[:4], "for=") {
+						hop = parseHop(strings.Trim(pair[4:], `"`))
+					}
+				}
+				hops = append(hops, hop)
+			}
+		}
+		return hops
+	}
+	for _, value := range header.Values("X-Forwarded-For") {
+		for _, hop := range strings.Split(value, ",") {
+			hops = append(hops, parseHop(strings.TrimSpace(hop)))
+		}
+	}
+	return hops
+}
+
+// parseHop parses an address that may carry a port or IPv6 brackets.
+func parseHop(hop string) net.IP {
+	if host, _, err := net.SplitHostPort(hop); err == nil {
+		hop = host
+	}
+	return net.ParseIP(strings.Trim(hop, "[]"))
+}
+
+// containsIP reports whether ip is in any of nets.
+func containsIP(nets []*net.IPNet, ip net.IP) bool {
+	for _, network := range nets {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
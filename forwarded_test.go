@@ -0,0 +1,118 @@
+package badge
+
+import (
+	"net"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	defer func(nets []*net.IPNet) { trustedProxies = nets }(trustedProxies)
+	var err error
+	trustedProxies, err = parseNets([]string{"10.0.0.0/8", "::1", "2001:db8:ffff::/48"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name      string
+		remote    string
+		forwarded []string
+		xff       []string
+		want      string
+	}{
+		{"direct", "192.0.2.1:1234", nil, nil, "192.0.2.1"},
+		{"untrusted remote ignores headers", "192.0.2.1:1234", nil, []string{"198.51.100.7"}, "192.0.2.1"},
+		{"trusted proxy", "10.0.0.1:1234", nil, []string{"198.51.100.7"}, "198.51.100.7"},
+		{"spoofed first hop", "10.0.0.1:1234", nil, []string{"203.0.113.9, 198.51.100.7"}, "198.51.100.7"},
+		{"chain of proxies", "10.0.0.1:1234", nil, []string{"198.51.100.7, 10.0.0.2, 10.0.0.3"}, "198.51.100.7"},
+		{"header lines in order", "10.0.0.1:1234", nil, []string{"203.0.113.9", "198.51.100.7, 10.0.0.2"}, "198.51.100.7"},
+		{"only proxies", "10.0.0.1:1234", nil, []string{"10.0.0.2"}, "10.0.0.2"},
+		{"trusted proxy without header", "10.0.0.1:1234", nil, nil, "10.0.0.1"},
+		{"hop with port", "10.0.0.1:1234", nil, []string{"198.51.100.7:5555"}, "198.51.100.7"},
+		{"invalid hop", "10.0.0.1:1234", nil, []string{"unknown"}, ""},
+		{"invalid hop behind untrusted", "10.0.0.1:1234", nil, []string{"garbage, 198.51.100.7"}, "198.51.100.7"},
+		{"ipv6 remote", "[::1]:1234", nil, []string{"2001:db8::7"}, "2001:db8::7"},
+		{"ipv6 proxy range", "[2001:db8:ffff::1]:443", nil, []string{"198.51.100.7"}, "198.51.100.7"},
+		{"ipv4 mapped remote", "[::ffff:10.0.0.1]:1234", nil, []string{"198.51.100.7"}, "198.51.100.7"},
+		{"remote without port", "10.0.0.1", nil, []string{"198.51.100.7"}, "198.51.100.7"},
+		{"invalid remote", "somewhere", nil, []string{"198.51.100.7"}, ""},
+		{"forwarded", "10.0.0.1:1234", []string{"for=198.51.100.7;proto=https"}, nil, "198.51.100.7"},
+		{"forwarded wins over xff", "10.0.0.1:1234", []string{"for=198.51.100.7"}, []string{"203.0.113.9"}, "198.51.100.7"},
+		{"forwarded ipv6 with port", "10.0.0.1:1234", []string{`for=203.0.113.9, For="[2001:db8::7]:4711"`}, nil, "2001:db8::7"},
+		{"forwarded chain", "10.0.0.1:1234", []string{"for=198.51.100.7", "for=10.0.0.2;by=10.0.0.1"}, nil, "198.51.100.7"},
+		{"forwarded obfuscated", "10.0.0.1:1234", []string{"for=_hidden"}, nil, ""},
+		{"forwarded unknown", "10.0.0.1:1234", []string{"for=unknown"}, nil, ""},
+		{"forwarded without for", "10.0.0.1:1234", []string{"proto=https"}, nil, ""},
+		{"forwarded from untrusted", "192.0.2.1:1234", []string{"for=198.51.100.7"}, nil, "192.0.2.1"},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.RemoteAddr = tt.remote
+		for _, value := range tt.forwarded {
+			r.Header.Add("Forwarded", value)
+		}
+		for _, value := range tt.xff {
+			r.Header.Add("X-Forwarded-For", value)
+		}
+		got := clientIP(r)
+		if tt.want == "" {
+			if got != nil {
+				t.Errorf("%s: got %v, want nil", tt.name, got)
+			}
+			continue
+		}
+		if !got.Equal(net.ParseIP(tt.want)) {
+			t.Errorf("%s: got %v, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestAllowedIPBehindProxy(t *testing.T) {
+	defer func(trusted, allowed []*net.IPNet) { trustedProxies, allowedNets = trusted, allowed }(trustedProxies, allowedNets)
+	trustedProxies, _ = parseNets([]string{"10.0.0.0/8"})
+	allowedNets, _ = parseNets([]string{"198.51.100.0/24"})
+	tests := []struct {
+		remote string
+		xff    string
+		want   bool
+	}{
+		{"10.0.0.1:1234", "198.51.100.7", true},
+		{"10.0.0.1:1234", "203.0.113.9", false},
+		{"10.0.0.1:1234", "198.51.100.7, 203.0.113.9", false},
+		{"203.0.113.9:1234", "198.51.100.7", false},
+		{"198.51.100.7:1234", "", true},
+		{"10.0.0.1:1234", "bogus", false},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.RemoteAddr = tt.remote
+		if tt.xff != "" {
+			r.Header.Set("X-Forwarded-For", tt.xff)
+		}
+		if granted, _ := requestAccess(r, false, "", ""); granted != tt.want {
+			t.Errorf("%s via %q: got %v, want %v", tt.remote, tt.xff, granted, tt.want)
+		}
+	}
+}
+
+func TestParseNets(t *testing.T) {
+	nets, err := parseNets([]string{"192.0.2.1", "2001:db8::1", "198.51.100.0/24", "2001:db8:1::/64"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, ip := range []string{"192.0.2.1", "::ffff:192.0.2.1", "2001:db8::1", "198.51.100.200", "2001:db8:1::42"} {
+		if !containsIP(nets, net.ParseIP(ip)) {
+			t.Errorf("%s: not contained", ip)
+		}
+	}
+	for _, ip := range []string{"192.0.2.2", "2001:db8::2", "198.51.101.1", "2001:db8:2::1"} {
+		if containsIP(nets, net.ParseIP(ip)) {
+			t.Errorf("%s: contained", ip)
+		}
+	}
+	for _, item := range []string{"192.0.2", "192.0.2.0/33", "example.com", ""} {
+		if _, err := parseNets([]string{item}); err == nil {
+			t.Errorf("%q: got no error", item)
+		}
+	}
+}
@@ -7,7 +7,9 @@ require (
 	github.com/bradleyfalzon/ghinstallation v1.1.1
 	github.com/golang/groupcache v0.0.0-20210331224755-41bb18bfe9da // indirect
 	github.com/google/go-github/v37 v37.0.1-0.20210728140053-0d84fe1b2f64
+	golang.org/x/image v0.0.0-20210628002857-a66eb6448b8d
 	golang.org/x/net v0.0.0-20210726213435-c6fcb2dbf985 // indirect
+	golang.org/x/text v0.3.6
 	google.golang.org/api v0.52.0 // indirect
 	google.golang.org/genproto v0.0.0-20210729151513-df9385d47c1b
 )
@@ -0,0 +1,109 @@
+package badge
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/bradleyfalzon/ghinstallation"
+	"github.com/google/go-github/v37/github"
+)
+
+// enterpriseHost is a GitHub Enterprise Server instance with its own App.
+type enterpriseHost struct {
+	appID     int64
+	keySecret string
+}
+
+// enterpriseHosts are the configured GHES instances by host name.
+var enterpriseHosts map[string]enterpriseHost
+
+// enterpriseTransports authenticate as the App of each GHES instance.
+var enterpriseTransports = make(map[string]*ghinstallation.AppsTransport)
+
+// parseEnterpriseHosts parses entries of the form "host=appID:secretName".
+func parseEnterpriseHosts(list []string) (map[string]enterpriseHost, error) {
+	hosts := make(map[string]enterpriseHost, len(list))
+	for _, entry := range list {
+		eq := strings.Index(entry, "=")
+		colon := strings.Index(entry, ":")
+		if eq <= 0 || colon < eq || colon == len(entry)-1 {
+			return nil, errors.New("entries must be host=appID:secretName")
+		}
+		host := entry[:eq]
+		if !validHostName(host) {
+			return nil, errors.New("invalid host " + host)
+		}
+		appID, err := strconv.ParseInt(entry[eq+1:colon], 10, 64)
+		if err != nil || appID <= 0 {
+			return nil, errors.New("invalid app ID of " + host)
+		}
+		hosts[strings.ToLower(host)] = enterpriseHost{appID: appID, keySecret: entry[colon+1:]}
+	}
+	return hosts, nil
+}
+
+// validHostName reports whether host is a bare host name
+// that can be put into URLs as is.
+func validHostName(host string) bool {
+	if host == "" || strings.ContainsAny(host, " \t\r\n/\\?#@%") {
+		return false
+	}
+	u, err := url.Parse("https://" + host)
+	return err == nil && u.Host == host && u.Hostname() != ""
+}
+
+// splitHostPrefix splits a param of n slash-separated parts that may be
+// prefixed with a configured GHES host, such as owner/repo or host/owner/repo.
+func splitHostPrefix(param string, n int) (string, []string, error) {
+	parts := strings.Split(param, "/")
+	if len(parts) != n+1 {
+		return "", parts, nil
+	}
+	host := strings.ToLower(parts[0])
+	if _, ok := enterpriseHosts[host]; !ok {
+		return "", nil, errors.New("Unknown GitHub host " + host)
+	}
+	return host, parts[1:], nil
+}
+
+// setupEnterpriseTransports creates the App transports of all GHES instances.
+func setupEnterpriseTransports() error {
+	for host, ent := range enterpriseHosts {
+		privateKey, err := githubPrivateKey(ent.keySecret)
+		if err != nil {
+			return err
+		}
+		tr, err := newGitHubTransport(ent.appID, privateKey)
+		if err != nil {
+			return err
+		}
+		tr.BaseURL = "https://" + host + "/api/v3"
+		enterpriseTransports[host] = tr
+	}
+	return nil
+}
+
+// appsTransportFor returns the App transport of a host, "" being github.com.
+func appsTransportFor(host string) (*ghinstallation.AppsTransport, error) {
+	if host == "" {
+		return appsTransport, nil
+	}
+	tr, ok := enterpriseTransports[host]
+	if !ok {
+		return nil, errors.New("Unknown GitHub host " + host)
+	}
+	return tr, nil
+}
+
+// newHostClient creates a client for the API of a host, "" being github.com.
+func newHostClient(host string, tr http.RoundTripper) (*github.Client, error) {
+	httpClient := &http.Client{Transport: tr}
+	if host == "" {
+		return github.NewClient(httpClient), nil
+	}
+	base := "https://" + host + "/api/v3/"
+	return github.NewEnterpriseClient(base, "https://"+host+"/api/uploads/", httpClient)
+}
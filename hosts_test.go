@@ -0,0 +1,58 @@
+package badge
+
+import "testing"
+
+func TestParseEnterpriseHosts(t *testing.T) {
+	hosts, err := parseEnterpriseHosts([]string{
+		"GHE.example.com=12:projects/p/secrets/key/versions/1",
+		"ghe-2.internal=7:key",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]enterpriseHost{
+		"ghe.example.com": {appID: 12, keySecret: "projects/p/secrets/key/versions/1"},
+		"ghe-2.internal":  {appID: 7, keySecret: "key"},
+	}
+	for host, ent := range want {
+		if hosts[host] != ent {
+			t.Errorf("%s: got %+v, want %+v", host, hosts[host], ent)
+		}
+	}
+	invalid := []string{
+		"ghe.example.com",
+		"=12:key",
+		"ghe.example.com=12",
+		"ghe.example.com=12:",
+		"ghe.example.com=abc:key",
+		"ghe.example.com=0:key",
+		"https://ghe.example.com=12:key",
+		"ghe.example.com/api=12:key",
+		"ghe example.com=12:key",
+		" ghe.example.com=12:key",
+		"ghe.example.com\t=12:key",
+		"user@ghe.example.com=12:key",
+		"ghe.example.com?x=12:key",
+		"ghe.example.com#x=12:key",
+		"ghe%2eexample.com=12:key",
+		"ghe.example.com:8443=12:key",
+	}
+	for _, entry := range invalid {
+		if _, err := parseEnterpriseHosts([]string{entry}); err == nil {
+			t.Errorf("%q: got no error", entry)
+		}
+	}
+}
+
+func TestNewHostClient(t *testing.T) {
+	client, err := newHostClient("ghe.example.com", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := client.BaseURL.String(); got != "https://ghe.example.com/api/v3/" {
+		t.Errorf("got base URL %s", got)
+	}
+	if _, err := newHostClient("ghe.example.com\x7f", nil); err == nil {
+		t.Error("invalid host: got no error")
+	}
+}
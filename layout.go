@@ -0,0 +1,53 @@
+package badge
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+)
+
+// maxLayoutWidth caps the width params, in pixels.
+const maxLayoutWidth = 2000
+
+// widthParam parses a width param, 0 if it is not set.
+func widthParam(r *http.Request, key string) (int, error) {
+	param := r.FormValue(key)
+	if param == "" {
+		return 0, nil
+	}
+	width, err := strconv.Atoi(param)
+	if err != nil || width <= 0 || width > maxLayoutWidth {
+		return 0, errors.New("Invalid " + key)
+	}
+	return width, nil
+}
+
+// layoutWidths applies the width options of the badge to the natural
+// widths of its segments. Extra width goes to the status segment, which
+// is also the one shrunk to fit MaxWidth, by at most room pixels.
+// It returns by how many pixels the status was shrunk.
+func (b *Badge) layoutWidths(widths []int, room int) int {
+	if b.LabelWidth > widths[0] {
+		widths[0] = b.LabelWidth
+	}
+	width := 0
+	for _, w := range widths {
+		width += w
+	}
+	if b.MinWidth > width {
+		widths[1] += b.MinWidth - width
+		width = b.MinWidth
+	}
+	if b.MaxWidth <= 0 || width <= b.MaxWidth {
+		return 0
+	}
+	shrink := width - b.MaxWidth
+	if shrink > room {
+		shrink = room
+	}
+	if shrink < 0 {
+		shrink = 0
+	}
+	widths[1] -= shrink
+	return shrink
+}
@@ -0,0 +1,151 @@
+package badge
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// defaultLiveInterval is how often live badges resolve again unless
+// configured otherwise. Each open page costs a resolution, and so GitHub
+// API quota, per interval, shared only with pages of the same badge.
+const defaultLiveInterval = 2 * time.Minute
+
+// livePollInterval is how often live badges resolve again,
+// no more often than lookups are shared for.
+var livePollInterval = defaultLiveInterval
+
+const (
+	// liveStreamDuration bounds an event stream, below the function timeout.
+	// Browsers reconnect on their own.
+	liveStreamDuration = 5 * time.Minute
+	// liveRetry is the reconnect delay suggested to browsers, in milliseconds.
+	liveRetry = 5000
+)
+
+var liveTemplate = template.Must(template.New("live").Parse(`<!DOCTYPE html>
+<html>
+<head><title>{{.Title}}</title></head>
+<body style="margin:0;display:flex;align-items:center;justify-content:center;height:100vh">
+<img id="badge" src="{{.BadgeURL}}" alt="{{.Title}}">
+<script>
+const badge = document.getElementById("badge");
+const src = badge.src;
+new EventSource(location.href).addEventListener("update", () => {
+  badge.src = src + "&_=" + Date.now();
+});
+</script>
+</body>
+</html>
+`))
+
+// LiveHTTP is a HTTP cloud function serving a page with a badge that
+// updates itself, for dashboards. It takes the params of GenBadgeHTTP,
+// which must be deployed on the same host. The page subscribes to the same
+// URL as an event stream, which resolves the badge every livePollInterval
+// and sends an update event whenever its value changes.
+//
+// Updates are polled, not pushed by webhooks, so they arrive up to an
+// interval late. Cloud Functions may also buffer the stream, in which case
+// events only arrive when it ends after liveStreamDuration.
+func LiveHTTP(w http.ResponseWriter, r *http.Request) {
+	setup()
+	if !requireFeature(w, featureLive) {
+		return
+	}
+	signed, err := verifySignedLink(r, time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	query, err := parseBadgeQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	query.allowPrivate, _ = requestAccess(r, signed, query.Host, query.Owner)
+	if strings.Contains(r.Header.Get("Accept"), "text/event-stream") {
+		streamBadge(w, r, query)
+		return
+	}
+	// The query, including keys and signatures, only goes to this host.
+	badgeURL := url.URL{Scheme: "https", Host: r.Host, Path: "/GenBadgeHTTP", RawQuery: r.URL.RawQuery}
+	title := r.FormValue("subject")
+	if title == "" {
+		title = query.Badge
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("X-Robots-Tag", "noindex")
+	liveTemplate.Execute(w, map[string]interface{}{
+		"Title":    title,
+		"BadgeURL": badgeURL.String(),
+	})
+}
+
+// liveEvent is the data of an update event.
+type liveEvent struct {
+	Value string `json:"value,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// streamBadge sends an update event whenever the resolution of a query
+// changes. Event IDs identify the resolution, so reconnecting browsers
+// only get an event if it changed while they were gone.
+func streamBadge(w http.ResponseWriter, r *http.Request, query *badgeQuery) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	ctx := r.Context()
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	fmt.Fprintf(w, "retry: %d\n\n", liveRetry)
+	flusher.Flush()
+	last := r.Header.Get("Last-Event-ID")
+	ticker := time.NewTicker(livePollInterval)
+	defer ticker.Stop()
+	deadline := time.NewTimer(liveStreamDuration)
+	defer deadline.Stop()
+	for {
+		var event liveEvent
+		var id string
+		res, err := resolve(ctx, query)
+		if err != nil {
+			event.Error = err.Error()
+			id = liveEventID(event.Error)
+		} else {
+			event.Value = res.Status
+			id = liveEventID(res.key())
+		}
+		if id != last {
+			data, _ := json.Marshal(&event)
+			fmt.Fprintf(w, "id: %s\nevent: update\ndata: %s\n\n", id, data)
+			last = id
+		} else {
+			// Keep proxies from closing the idle connection.
+			fmt.Fprint(w, ": ping\n\n")
+		}
+		flusher.Flush()
+		select {
+		case <-ticker.C:
+		case <-deadline.C:
+			return
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// liveEventID returns the event ID of a resolution key.
+func liveEventID(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:8])
+}
@@ -0,0 +1,118 @@
+package badge
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"path"
+	"strings"
+
+	"github.com/google/go-github/v37/github"
+)
+
+// maxLogoSize caps custom logo images.
+const maxLogoSize = 32 << 10
+
+// logoTypes are the media types of logo images by file extension.
+var logoTypes = map[string]string{
+	".svg":  "image/svg+xml",
+	".png":  "image/png",
+	".jpg":  "image/jpeg",
+	".jpeg": "image/jpeg",
+	".gif":  "image/gif",
+}
+
+// resolveLogo turns the logo param into a data URI and reports whether
+// it came from a private repo. The param is either a base64 image data URI
+// or the path of an image in the queried repo.
+func resolveLogo(ctx context.Context, query *badgeQuery, logo string) (string, bool, error) {
+	if strings.HasPrefix(logo, "data:") {
+		uri, err := parseLogoDataURI(logo)
+		return uri, false, err
+	}
+	logoPath := strings.TrimPrefix(logo, "/")
+	if !validLogoPath(logoPath) {
+		return "", false, errors.New("Invalid logo path")
+	}
+	mediaType := logoTypes[strings.ToLower(path.Ext(logo))]
+	if mediaType == "" {
+		return "", false, errors.New("Unsupported logo type")
+	}
+	if err := requireRepo(query); err != nil {
+		return "", false, err
+	}
+	client, err := newRepoClient(ctx, query.Host, query.Owner, query.Repo)
+	if err != nil {
+		return "", false, err
+	}
+	private, err := isPrivateRepo(ctx, client, query.Owner, query.Repo)
+	if err != nil {
+		return "", false, errors.New("Failed to get repo")
+	}
+	if private && !query.allowPrivate {
+		return "", false, errors.New("Repo is private")
+	}
+	key := strings.Join([]string{"logo", query.Host, query.Owner, query.Repo, query.Branch, logoPath}, "\x00")
+	val, err := lookups.do(ctx, key, func(ctx context.Context) (interface{}, error) {
+		opts := &github.RepositoryContentGetOptions{Ref: query.Branch}
+		file, _, _, err := client.Repositories.GetContents(ctx, query.Owner, query.Repo, logoPath, opts)
+		if err != nil {
+			return nil, err
+		}
+		if file == nil {
+			return nil, errors.New("not a file")
+		}
+		if file.GetSize() > maxLogoSize {
+			return nil, errors.New("too large")
+		}
+		content, err := file.GetContent()
+		if err != nil {
+			return nil, err
+		}
+		return "data:" + mediaType + ";base64," + base64.StdEncoding.EncodeToString([]byte(content)), nil
+	})
+	if err != nil {
+		return "", false, errors.New("Failed to get logo: " + err.Error())
+	}
+	return val.(string), private, nil
+}
+
+// validLogoPath reports whether p is a clean relative path in a repo.
+// The contents API resolves dot segments, which would otherwise reach
+// other repos of the installation.
+func validLogoPath(p string) bool {
+	if p == "" || path.Clean(p) != p {
+		return false
+	}
+	for _, segment := range strings.Split(p, "/") {
+		if segment == "" || segment == "." || segment == ".." {
+			return false
+		}
+	}
+	return true
+}
+
+// parseLogoDataURI checks a base64 image data URI and returns it re-encoded,
+// which makes it safe to embed in markup.
+func parseLogoDataURI(uri string) (string, error) {
+	sep := strings.Index(uri, ",")
+	if sep < 0 || !strings.HasSuffix(uri[:sep], ";base64") {
+		return "", errors.New("Logo must be a base64 data URI")
+	}
+	mediaType := strings.TrimSuffix(strings.TrimPrefix(uri[:sep], "data:"), ";base64")
+	known := false
+	for _, logoType := range logoTypes {
+		known = known || mediaType == logoType
+	}
+	if !known {
+		return "", errors.New("Unsupported logo type")
+	}
+	data, err := base64.StdEncoding.DecodeString(uri[sep+1:])
+	if err != nil {
+		return "", errors.New("Logo must be a base64 data URI")
+	}
+	if len(data) > maxLogoSize {
+		return "", errors.New("Logo too large")
+	}
+	return "data:" + mediaType + ";base64," + base64.StdEncoding.EncodeToString(data), nil
+}
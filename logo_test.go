@@ -0,0 +1,46 @@
+package badge
+
+import (
+	"context"
+	"testing"
+)
+
+func TestValidLogoPath(t *testing.T) {
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"logo.png", true},
+		{".github/logo.svg", true},
+		{"docs/img/logo..png", true},
+		{"", false},
+		{"../../private-repo/contents/logo.png", false},
+		{"docs/../../other/contents/logo.png", false},
+		{"docs/..", false},
+		{"./logo.png", false},
+		{"docs/./logo.png", false},
+		{"docs//logo.png", false},
+		{"docs/", false},
+		{"/logo.png", false},
+	}
+	for _, tt := range tests {
+		if got := validLogoPath(tt.path); got != tt.want {
+			t.Errorf("%q: got %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestResolveLogoTraversal(t *testing.T) {
+	query := &badgeQuery{Owner: "org", Repo: "public-repo"}
+	for _, logo := range []string{
+		"../../private-repo/contents/logo.png",
+		"/../../private-repo/contents/logo.png",
+		"img/../../../private-repo/contents/logo.png",
+	} {
+		// Rejected before any client for the repo is made.
+		_, _, err := resolveLogo(context.Background(), query, logo)
+		if err == nil || err.Error() != "Invalid logo path" {
+			t.Errorf("%s: got %v, want invalid logo path", logo, err)
+		}
+	}
+}
@@ -0,0 +1,24 @@
+package badge
+
+import "strings"
+
+// maintenanceText returns the status text overriding badges of the queried
+// repo during maintenance, or "" if no override applies.
+// The override is static deployment config. Setting and clearing it at
+// runtime needs an admin API backed by state shared between instances,
+// which this tree does not have yet.
+func maintenanceText(query *badgeQuery) string {
+	if config.Maintenance == "" {
+		return ""
+	}
+	if len(config.MaintenanceRepos) == 0 {
+		return config.Maintenance
+	}
+	repo := query.Owner + "/" + query.Repo
+	for _, affected := range config.MaintenanceRepos {
+		if strings.EqualFold(affected, repo) {
+			return config.Maintenance
+		}
+	}
+	return ""
+}
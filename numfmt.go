@@ -0,0 +1,101 @@
+package badge
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Number formats, the values of the numfmt param.
+const (
+	numfmtSI        = "si"
+	numfmtThousands = "thousands"
+	numfmtPercent   = "percent"
+)
+
+// unitBytes is the unit param value formatting byte counts.
+const unitBytes = "bytes"
+
+// siPrefixes are the prefixes of SI formatted numbers, by power of 1000.
+var siPrefixes = []string{"", "k", "M", "G", "T", "P", "E"}
+
+// byteUnits are the units of byte counts, by power of 1024.
+var byteUnits = []string{" B", " KiB", " MiB", " GiB", " TiB", " PiB", " EiB"}
+
+// formatNumeric formats a number for display:
+// "si" as 1.2M, "thousands" as 1,234,567 and "percent" of a ratio as 87.3%.
+func formatNumeric(value float64, format string) (string, error) {
+	switch format {
+	case numfmtSI:
+		return formatSI(value, 1000, siPrefixes), nil
+	case numfmtThousands:
+		return formatThousands(value), nil
+	case numfmtPercent:
+		return trimZeros(strconv.FormatFloat(value*100, 'f', 1, 64)) + "%", nil
+	}
+	return "", errors.New("Unknown numfmt")
+}
+
+// formatUnit formats a quantity in the given unit for display,
+// "bytes" as 4.2 MiB.
+func formatUnit(value float64, unit string) (string, error) {
+	switch unit {
+	case unitBytes:
+		return formatSI(value, 1024, byteUnits), nil
+	}
+	return "", errors.New("Unknown unit")
+}
+
+// formatSI scales a number down by powers of base and appends the prefix
+// of the power, keeping three significant digits at most.
+func formatSI(value, base float64, prefixes []string) string {
+	i := 0
+	for math.Abs(value) >= base && i < len(prefixes)-1 {
+		value /= base
+		i++
+	}
+	if i == 0 {
+		return formatNumber(value) + prefixes[0]
+	}
+	digits := 1
+	if math.Abs(value) >= 100 {
+		digits = 0
+		// Rounding up may reach the next power, as in 999.9k.
+		if math.Abs(math.Round(value)) >= base && i < len(prefixes)-1 {
+			value /= base
+			i++
+			digits = 1
+		}
+	}
+	return trimZeros(strconv.FormatFloat(value, 'f', digits, 64)) + prefixes[i]
+}
+
+// formatThousands groups the integer digits of a number by commas.
+func formatThousands(value float64) string {
+	text := formatNumber(value)
+	sign := ""
+	if strings.HasPrefix(text, "-") {
+		sign, text = "-", text[1:]
+	}
+	integer, fraction := text, ""
+	if i := strings.IndexByte(text, '.'); i >= 0 {
+		integer, fraction = text[:i], text[i:]
+	}
+	var grouped strings.Builder
+	for i, digit := range integer {
+		if i > 0 && (len(integer)-i)%3 == 0 {
+			grouped.WriteByte(',')
+		}
+		grouped.WriteRune(digit)
+	}
+	return sign + grouped.String() + fraction
+}
+
+// trimZeros drops trailing zeros of a fraction, and the point if nothing is left.
+func trimZeros(text string) string {
+	if !strings.Contains(text, ".") {
+		return text
+	}
+	return strings.TrimSuffix(strings.TrimRight(text, "0"), ".")
+}
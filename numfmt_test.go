@@ -0,0 +1,94 @@
+package badge
+
+import "testing"
+
+func TestFormatNumeric(t *testing.T) {
+	tests := []struct {
+		value  float64
+		format string
+		want   string
+	}{
+		{0, numfmtSI, "0"},
+		{999, numfmtSI, "999"},
+		{0.5, numfmtSI, "0.5"},
+		{1000, numfmtSI, "1k"},
+		{1234, numfmtSI, "1.2k"},
+		{1260, numfmtSI, "1.3k"},
+		{12345, numfmtSI, "12.3k"},
+		{123456, numfmtSI, "123k"},
+		{999499, numfmtSI, "999k"},
+		{999500, numfmtSI, "1M"},
+		{1500000, numfmtSI, "1.5M"},
+		{-1500000, numfmtSI, "-1.5M"},
+		{-999999, numfmtSI, "-1M"},
+		{2.5e18, numfmtSI, "2.5E"},
+		{2.5e21, numfmtSI, "2500E"},
+		{0, numfmtThousands, "0"},
+		{999, numfmtThousands, "999"},
+		{1000, numfmtThousands, "1,000"},
+		{1234567, numfmtThousands, "1,234,567"},
+		{123456, numfmtThousands, "123,456"},
+		{-1234.5, numfmtThousands, "-1,234.5"},
+		{0.25, numfmtThousands, "0.25"},
+		{1e21, numfmtThousands, "1,000,000,000,000,000,000,000"},
+		{0.873, numfmtPercent, "87.3%"},
+		{1, numfmtPercent, "100%"},
+		{0.5, numfmtPercent, "50%"},
+		{0.12345, numfmtPercent, "12.3%"},
+		{-0.05, numfmtPercent, "-5%"},
+	}
+	for _, tt := range tests {
+		got, err := formatNumeric(tt.value, tt.format)
+		if err != nil || got != tt.want {
+			t.Errorf("%v as %s: got %q, %v, want %q", tt.value, tt.format, got, err, tt.want)
+		}
+	}
+	if _, err := formatNumeric(1, "roman"); err == nil {
+		t.Error("unknown format: got no error")
+	}
+}
+
+func TestFormatUnit(t *testing.T) {
+	tests := []struct {
+		value float64
+		want  string
+	}{
+		{0, "0 B"},
+		{1023, "1023 B"},
+		{1024, "1 KiB"},
+		{1536, "1.5 KiB"},
+		{4.2 * 1024 * 1024, "4.2 MiB"},
+		{1023.9 * 1024, "1 MiB"},
+		{100 * 1024 * 1024 * 1024, "100 GiB"},
+		{-2048, "-2 KiB"},
+	}
+	for _, tt := range tests {
+		got, err := formatUnit(tt.value, unitBytes)
+		if err != nil || got != tt.want {
+			t.Errorf("%v bytes: got %q, %v, want %q", tt.value, got, err, tt.want)
+		}
+	}
+	if _, err := formatUnit(1, "bits"); err == nil {
+		t.Error("unknown unit: got no error")
+	}
+}
+
+func TestTrimZeros(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"100", "100"},
+		{"1.50", "1.5"},
+		{"1.0", "1"},
+		{"10.00", "10"},
+		{"0.0", "0"},
+		{"-2.10", "-2.1"},
+		{"1.05", "1.05"},
+	}
+	for _, tt := range tests {
+		if got := trimZeros(tt.text); got != tt.want {
+			t.Errorf("%s: got %s, want %s", tt.text, got, tt.want)
+		}
+	}
+}
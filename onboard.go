@@ -0,0 +1,206 @@
+package badge
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/google/go-github/v37/github"
+)
+
+// onboardConcurrency bounds the repos scanned in parallel.
+const onboardConcurrency = 4
+
+// onboardRepo lists the badges found in one repo.
+type onboardRepo struct {
+	Repo      string            `json:"repo"`
+	Branch    string            `json:"branch"`
+	Workflows []onboardWorkflow `json:"workflows"`
+	Error     string            `json:"error,omitempty"`
+}
+
+// onboardWorkflow lists the badges of a workflow's latest successful run.
+type onboardWorkflow struct {
+	Name   string         `json:"name"`
+	Badges []onboardBadge `json:"badges"`
+}
+
+// onboardBadge is a suggested badge.
+type onboardBadge struct {
+	Name     string `json:"name"`
+	URL      string `json:"url"`
+	Markdown string `json:"markdown"`
+}
+
+// OnboardHTTP is a HTTP cloud function that suggests badges for an org.
+// It enumerates the repos the App is installed on, finds badge artifacts
+// of each workflow's latest successful run on the default branch, and
+// returns badge URLs as JSON, or Markdown with format=markdown.
+// Orgs on GHES instances are prefixed with the host, as in host/org.
+// It requires an API key valid for the org.
+func OnboardHTTP(w http.ResponseWriter, r *http.Request) {
+	setup()
+	if !requireFeature(w, featureOnboard) {
+		return
+	}
+	ctx := r.Context()
+	if r.FormValue("org") == "" {
+		http.Error(w, "Missing org key", http.StatusBadRequest)
+		return
+	}
+	host, orgParts, err := splitHostPrefix(r.FormValue("org"), 1)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(orgParts) != 1 || orgParts[0] == "" {
+		http.Error(w, "Invalid org key", http.StatusBadRequest)
+		return
+	}
+	org := orgParts[0]
+	if !requireAPIKey(w, r, host, org) {
+		return
+	}
+	base := r.FormValue("base")
+	if base == "" {
+		base = "https://" + r.Host + "/GenBadgeHTTP"
+	}
+	// Get installation of org.
+	tr, err := appsTransportFor(host)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	appClient, err := newHostClient(host, tr)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	installation, _, err := appClient.Apps.FindOrganizationInstallation(ctx, org)
+	if err != nil || installation == nil {
+		http.Error(w, "Can't find installation for org", http.StatusBadRequest)
+		return
+	}
+	client, err := newInstallationClient(host, installation.GetID())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	// List installed repos.
+	var repos []*github.Repository
+	err = forEachPage(func(opts *github.ListOptions) (*github.Response, error) {
+		list, res, err := client.Apps.ListRepos(ctx, opts)
+		if err != nil {
+			return nil, err
+		}
+		repos = append(repos, list.Repositories...)
+		return res, nil
+	})
+	if err != nil {
+		http.Error(w, "Failed to list repos", http.StatusBadRequest)
+		return
+	}
+	// Scan repos.
+	results := make([]onboardRepo, len(repos))
+	sem := make(chan struct{}, onboardConcurrency)
+	var wg sync.WaitGroup
+	for i, repo := range repos {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, repo *github.Repository) {
+			defer wg.Done()
+			results[i] = scanRepoBadges(ctx, client, host, base, repo)
+			<-sem
+		}(i, repo)
+	}
+	wg.Wait()
+	if r.FormValue("format") == "markdown" {
+		w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+		writeOnboardMarkdown(w, results)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(results)
+}
+
+// scanRepoBadges finds the badge artifacts of all workflows of a repo.
+// Repos of GHES instances are named with the host prefix badge URLs take.
+func scanRepoBadges(ctx context.Context, client *github.Client, host, base string, repo *github.Repository) onboardRepo {
+	owner, name, branch := repo.GetOwner().GetLogin(), repo.GetName(), repo.GetDefaultBranch()
+	result := onboardRepo{Repo: repo.GetFullName(), Branch: branch}
+	if host != "" {
+		result.Repo = host + "/" + result.Repo
+	}
+	var workflows []*github.Workflow
+	err := forEachPage(func(opts *github.ListOptions) (*github.Response, error) {
+		list, res, err := client.Actions.ListWorkflows(ctx, owner, name, opts)
+		if err != nil {
+			return nil, err
+		}
+		workflows = append(workflows, list.Workflows...)
+		return res, nil
+	})
+	if err != nil {
+		result.Error = "Failed to list workflows"
+		return result
+	}
+	for _, workflow := range workflows {
+		runs, _, err := client.Actions.ListWorkflowRunsByID(ctx, owner, name, workflow.GetID(), &github.ListWorkflowRunsOptions{
+			Branch:      branch,
+			Event:       "push",
+			Status:      "success",
+			ListOptions: github.ListOptions{PerPage: 1},
+		})
+		if err != nil || len(runs.WorkflowRuns) == 0 {
+			continue
+		}
+		artifacts, _, err := client.Actions.ListWorkflowRunArtifacts(ctx, owner, name, runs.WorkflowRuns[0].GetID(), &github.ListOptions{PerPage: 100})
+		if err != nil {
+			continue
+		}
+		entry := onboardWorkflow{Name: workflow.GetName()}
+		for _, artifact := range artifacts.Artifacts {
+			badgeName := strings.TrimPrefix(artifact.GetName(), "badge_")
+			if badgeName == artifact.GetName() || artifact.GetExpired() {
+				continue
+			}
+			values := url.Values{
+				"repo":    {result.Repo},
+				"branch":  {branch},
+				"run":     {workflow.GetName()},
+				"badge":   {badgeName},
+				"subject": {badgeName},
+			}
+			badgeURL := base + "?" + values.Encode()
+			entry.Badges = append(entry.Badges, onboardBadge{
+				Name:     badgeName,
+				URL:      badgeURL,
+				Markdown: fmt.Sprintf("![%s](%s)", badgeName, badgeURL),
+			})
+		}
+		if len(entry.Badges) > 0 {
+			result.Workflows = append(result.Workflows, entry)
+		}
+	}
+	return result
+}
+
+// writeOnboardMarkdown writes one section per repo with badges.
+func writeOnboardMarkdown(w http.ResponseWriter, results []onboardRepo) {
+	for _, repo := range results {
+		if len(repo.Workflows) == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "## %s\n\n", repo.Repo)
+		for _, workflow := range repo.Workflows {
+			for _, badge := range workflow.Badges {
+				fmt.Fprintln(w, badge.Markdown)
+			}
+		}
+		fmt.Fprintln(w)
+	}
+}
@@ -0,0 +1,69 @@
+package badge
+
+import "strings"
+
+// palette maps color names to hex colors.
+type palette map[string]string
+
+// palettes are the values of the palette param and config.
+// All of them define the color names understood by badgen.net.
+var palettes = map[string]palette{
+	"default": namedColors,
+	"pastel": {
+		"green":  "#77C77E",
+		"blue":   "#6FA8DC",
+		"red":    "#E88A85",
+		"yellow": "#E6C75C",
+		"orange": "#F0A868",
+		"purple": "#A98ED8",
+		"pink":   "#E89AC7",
+		"grey":   "#AAA",
+		"gray":   "#AAA",
+		"cyan":   "#6CC5CF",
+		"black":  "#4A4A4A",
+	},
+	"high-contrast": {
+		"green":  "#060",
+		"blue":   "#004C99",
+		"red":    "#B00",
+		"yellow": "#7A5C00",
+		"orange": "#A04000",
+		"purple": "#5B1A9E",
+		"pink":   "#A0105F",
+		"grey":   "#555",
+		"gray":   "#555",
+		"cyan":   "#005F66",
+		"black":  "#000",
+	},
+	// Okabe-Ito colors, told apart with any kind of color blindness.
+	"colorblind-safe": {
+		"green":  "#009E73",
+		"blue":   "#0072B2",
+		"red":    "#D55E00",
+		"yellow": "#B8A000",
+		"orange": "#E69F00",
+		"purple": "#A0628A",
+		"pink":   "#CC79A7",
+		"grey":   "#999",
+		"gray":   "#999",
+		"cyan":   "#3A9AD9",
+		"black":  "#2A2A2A",
+	},
+}
+
+// defaultPalette is the palette of badges not asking for one.
+var defaultPalette = "default"
+
+// validPalette reports whether name names a palette.
+func validPalette(name string) bool {
+	_, ok := palettes[strings.ToLower(name)]
+	return ok || name == ""
+}
+
+// palette returns the palette of the badge, defaultPalette unless set.
+func (b *Badge) palette() palette {
+	if p, ok := palettes[strings.ToLower(b.Palette)]; ok {
+		return p
+	}
+	return palettes[defaultPalette]
+}
@@ -0,0 +1,37 @@
+package parse
+
+import (
+	"bufio"
+	"bytes"
+	"regexp"
+	"strings"
+)
+
+// benchstatDelta matches the delta column of a benchstat comparison.
+var benchstatDelta = regexp.MustCompile(`^([+-]?\d+(\.\d+)?%|~)$`)
+
+// parseBenchstat returns the delta of a benchstat comparison, of the
+// geomean row unless the benchmark option names another row.
+// The first matching row wins if the output compares several units.
+func parseBenchstat(data []byte, opts Options) (string, error) {
+	name := opts.Get("benchmark")
+	if name == "" {
+		name = "geomean"
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || fields[0] != name {
+			continue
+		}
+		for i := len(fields) - 1; i > 0; i-- {
+			if benchstatDelta.MatchString(fields[i]) {
+				return fields[i], nil
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	return "", errNoValue("benchstat")
+}
@@ -0,0 +1,27 @@
+package parse
+
+import (
+	"encoding/xml"
+	"strconv"
+)
+
+// parseCobertura returns the line coverage of a Cobertura XML report,
+// or the branch coverage with metric=branch.
+func parseCobertura(data []byte, opts Options) (string, error) {
+	var report struct {
+		LineRate   string `xml:"line-rate,attr"`
+		BranchRate string `xml:"branch-rate,attr"`
+	}
+	if err := xml.Unmarshal(data, &report); err != nil {
+		return "", err
+	}
+	rate := report.LineRate
+	if opts.Get("metric") == "branch" {
+		rate = report.BranchRate
+	}
+	ratio, err := strconv.ParseFloat(rate, 64)
+	if err != nil {
+		return "", errNoValue("cobertura")
+	}
+	return percent(ratio), nil
+}
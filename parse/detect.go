@@ -0,0 +1,65 @@
+package parse
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+)
+
+// Detect guesses the parser for data from its content: "json" for JSON
+// objects and arrays, "junit" or "cobertura" for XML reports, "lcov" for
+// lcov tracefiles and "plain" for anything else.
+func Detect(data []byte) string {
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) == 0 {
+		return "plain"
+	}
+	switch trimmed[0] {
+	case '{', '[':
+		// Scalars like 42 are plain text that happens to be JSON.
+		if json.Valid(trimmed) {
+			return "json"
+		}
+	case '<':
+		switch xmlRoot(trimmed) {
+		case "testsuites", "testsuite":
+			return "junit"
+		case "coverage":
+			return "cobertura"
+		}
+	default:
+		if bytes.HasPrefix(trimmed, []byte("TN:")) || bytes.HasPrefix(trimmed, []byte("SF:")) {
+			return "lcov"
+		}
+	}
+	return "plain"
+}
+
+// MayDetect reports whether Detect could recognize a report starting with
+// head, so that callers only need to read the rest of such files.
+func MayDetect(head []byte) bool {
+	trimmed := bytes.TrimSpace(head)
+	if len(trimmed) == 0 {
+		return false
+	}
+	switch trimmed[0] {
+	case '{', '[', '<':
+		return true
+	}
+	return bytes.HasPrefix(trimmed, []byte("TN:")) || bytes.HasPrefix(trimmed, []byte("SF:"))
+}
+
+// xmlRoot returns the name of the root element of an XML document,
+// empty if there is none.
+func xmlRoot(data []byte) string {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return ""
+		}
+		if start, ok := tok.(xml.StartElement); ok {
+			return start.Name.Local
+		}
+	}
+}
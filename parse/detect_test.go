@@ -0,0 +1,85 @@
+package parse
+
+import "testing"
+
+func TestDetect(t *testing.T) {
+	fixtures := []struct {
+		fixture string
+		want    string
+	}{
+		{"plain.txt", "plain"},
+		{"report.json", "json"},
+		{"junit-flat.xml", "junit"},
+		{"junit-nested.xml", "junit"},
+		{"junit-passing.xml", "junit"},
+		{"lcov.info", "lcov"},
+		{"cobertura.xml", "cobertura"},
+		{"benchstat.txt", "plain"},
+	}
+	for _, tt := range fixtures {
+		if got := Detect(readFixture(t, tt.fixture)); got != tt.want {
+			t.Errorf("%s: got %s, want %s", tt.fixture, got, tt.want)
+		}
+	}
+	texts := []struct {
+		text string
+		want string
+	}{
+		{"", "plain"},
+		{"  \n", "plain"},
+		{"42", "plain"},
+		{`"passing"`, "plain"},
+		{"{not json", "plain"},
+		{"[1, 2", "plain"},
+		{`{"coverage": 87}`, "json"},
+		{"<html><body>87%</body></html>", "plain"},
+		{"<not xml", "plain"},
+		{"SF:main.go\nLF:1\nLH:1", "lcov"},
+		{"TNT: 12", "plain"},
+	}
+	for _, tt := range texts {
+		if got := Detect([]byte(tt.text)); got != tt.want {
+			t.Errorf("%q: got %s, want %s", tt.text, got, tt.want)
+		}
+	}
+}
+
+// TestDetectedObjectsNeedPath checks that documents detected as JSON only
+// yield a value with a path, which is why callers fall back to plain text
+// when a detected parser fails.
+func TestDetectedObjectsNeedPath(t *testing.T) {
+	data := []byte(`{"coverage": 87}`)
+	p, _ := Lookup(Detect(data))
+	if _, err := p.Parse(data, options{}); err == nil {
+		t.Error("json object without path: got a value, want error")
+	}
+	if got, err := p.Parse(data, options{"path": "coverage"}); err != nil || got != "87" {
+		t.Errorf("json object with path: got %q, %v, want 87", got, err)
+	}
+}
+
+func TestMayDetect(t *testing.T) {
+	tests := []struct {
+		head string
+		want bool
+	}{
+		{"", false},
+		{"87.4%", false},
+		{"  {\"totals\": {\"cov", true},
+		{"[1, 2,", true},
+		{"<?xml version=\"1.0\"?><testsu", true},
+		{"TN:\nSF:main.go", true},
+		{"SF:main.go\nLF:", true},
+	}
+	for _, tt := range tests {
+		if got := MayDetect([]byte(tt.head)); got != tt.want {
+			t.Errorf("%q: got %v, want %v", tt.head, got, tt.want)
+		}
+	}
+	// Every report Detect recognizes must be read in full.
+	for _, fixture := range []string{"report.json", "junit-flat.xml", "junit-nested.xml", "lcov.info", "cobertura.xml"} {
+		if !MayDetect(readFixture(t, fixture)) {
+			t.Errorf("%s: got false, want true", fixture)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package parse
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// parseJSON returns the value at the dot-separated path option,
+// for example "totals.coverage" or "results.0.status".
+// Without a path the document itself must be a scalar.
+func parseJSON(data []byte, opts Options) (string, error) {
+	dec := json.NewDecoder(strings.NewReader(string(data)))
+	dec.UseNumber()
+	var doc interface{}
+	if err := dec.Decode(&doc); err != nil {
+		return "", err
+	}
+	if path := opts.Get("path"); path != "" {
+		for _, key := range strings.Split(path, ".") {
+			switch node := doc.(type) {
+			case map[string]interface{}:
+				var ok bool
+				if doc, ok = node[key]; !ok {
+					return "", fmt.Errorf("no key %q", key)
+				}
+			case []interface{}:
+				i, err := strconv.Atoi(key)
+				if err != nil || i < 0 || i >= len(node) {
+					return "", fmt.Errorf("no index %q", key)
+				}
+				doc = node[i]
+			default:
+				return "", fmt.Errorf("no key %q", key)
+			}
+		}
+	}
+	switch value := doc.(type) {
+	case string:
+		return value, nil
+	case json.Number:
+		return value.String(), nil
+	case bool:
+		return strconv.FormatBool(value), nil
+	case nil:
+		return "null", nil
+	default:
+		return "", errNoValue("json")
+	}
+}
@@ -0,0 +1,67 @@
+package parse
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strconv"
+)
+
+// junitSuite is a testsuite element. Nested suites only count
+// if their parent carries no totals itself.
+type junitSuite struct {
+	Tests    int          `xml:"tests,attr"`
+	Failures int          `xml:"failures,attr"`
+	Errors   int          `xml:"errors,attr"`
+	Skipped  int          `xml:"skipped,attr"`
+	Suites   []junitSuite `xml:"testsuite"`
+}
+
+// totals sums up the tests of a suite.
+func (s *junitSuite) totals() junitSuite {
+	if s.Tests > 0 || len(s.Suites) == 0 {
+		return *s
+	}
+	var sum junitSuite
+	for i := range s.Suites {
+		t := s.Suites[i].totals()
+		sum.Tests += t.Tests
+		sum.Failures += t.Failures
+		sum.Errors += t.Errors
+		sum.Skipped += t.Skipped
+	}
+	return sum
+}
+
+// parseJUnit summarizes a JUnit XML report as "N passed" or
+// "N passed, M failed". The metric option selects a single count:
+// "tests", "passed", "failed" or "skipped".
+func parseJUnit(data []byte, opts Options) (string, error) {
+	// The root is either testsuites or a single testsuite.
+	var root junitSuite
+	if err := xml.Unmarshal(data, &root); err != nil {
+		return "", err
+	}
+	t := root.totals()
+	if t.Tests == 0 {
+		return "", errNoValue("junit")
+	}
+	failed := t.Failures + t.Errors
+	passed := t.Tests - failed - t.Skipped
+	switch opts.Get("metric") {
+	case "":
+		if failed == 0 {
+			return fmt.Sprintf("%d passed", passed), nil
+		}
+		return fmt.Sprintf("%d passed, %d failed", passed, failed), nil
+	case "tests":
+		return strconv.Itoa(t.Tests), nil
+	case "passed":
+		return strconv.Itoa(passed), nil
+	case "failed":
+		return strconv.Itoa(failed), nil
+	case "skipped":
+		return strconv.Itoa(t.Skipped), nil
+	default:
+		return "", fmt.Errorf("invalid metric %q", opts.Get("metric"))
+	}
+}
@@ -0,0 +1,36 @@
+package parse
+
+import (
+	"bufio"
+	"bytes"
+	"strconv"
+	"strings"
+)
+
+// parseLcov returns the line coverage of an lcov tracefile,
+// or the branch coverage with metric=branch.
+func parseLcov(data []byte, opts Options) (string, error) {
+	foundKey, hitKey := "LF:", "LH:"
+	if opts.Get("metric") == "branch" {
+		foundKey, hitKey = "BRF:", "BRH:"
+	}
+	var found, hit int
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, foundKey) {
+			n, _ := strconv.Atoi(line[len(foundKey):])
+			found += n
+		} else if strings.HasPrefix(line, hitKey) {
+			n, _ := strconv.Atoi(line[len(hitKey):])
+			hit += n
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	if found == 0 {
+		return "", errNoValue("lcov")
+	}
+	return percent(float64(hit) / float64(found)), nil
+}
@@ -0,0 +1,88 @@
+// Package parse extracts badge values from artifact files.
+//
+// Parsers are registered by name and selected with the parse param of a
+// badge. Formats are added by registering another Parser, usually from an
+// init function.
+package parse
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// Options holds the params of a badge request.
+// Parsers read format specific settings from it.
+type Options interface {
+	Get(key string) string
+}
+
+// Parser extracts a badge value from the contents of an artifact file.
+type Parser interface {
+	Parse(data []byte, opts Options) (string, error)
+}
+
+// ParserFunc adapts a function to a Parser.
+type ParserFunc func(data []byte, opts Options) (string, error)
+
+// Parse calls f.
+func (f ParserFunc) Parse(data []byte, opts Options) (string, error) {
+	return f(data, opts)
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]Parser)
+)
+
+// Register makes a parser available by name.
+// It panics if the name is already taken.
+func Register(name string, p Parser) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if _, dup := registry[name]; dup {
+		panic("parse: duplicate parser " + name)
+	}
+	registry[name] = p
+}
+
+// Lookup returns the parser registered under name.
+func Lookup(name string) (Parser, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	p, ok := registry[name]
+	return p, ok
+}
+
+// Names returns the names of all registered parsers, sorted.
+func Names() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func init() {
+	Register("plain", ParserFunc(parsePlain))
+	Register("json", ParserFunc(parseJSON))
+	Register("junit", ParserFunc(parseJUnit))
+	Register("lcov", ParserFunc(parseLcov))
+	Register("cobertura", ParserFunc(parseCobertura))
+	Register("benchstat", ParserFunc(parseBenchstat))
+}
+
+// percent formats a ratio between 0 and 1 as a percentage.
+func percent(ratio float64) string {
+	return strconv.FormatFloat(math.Round(ratio*10000)/100, 'f', -1, 64) + "%"
+}
+
+// errNoValue is returned by parsers that found nothing to show.
+func errNoValue(format string) error {
+	return fmt.Errorf("no %s value found", format)
+}
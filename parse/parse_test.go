@@ -0,0 +1,98 @@
+package parse
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// options is a fixed set of parser options.
+type options map[string]string
+
+func (o options) Get(key string) string { return o[key] }
+
+// readFixture returns the contents of a file in testdata.
+func readFixture(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestParsers(t *testing.T) {
+	tests := []struct {
+		parser  string
+		fixture string
+		opts    options
+		want    string
+		wantErr bool
+	}{
+		{parser: "plain", fixture: "plain.txt", want: "87.4%"},
+		{parser: "json", fixture: "report.json", opts: options{"path": "totals.coverage"}, want: "87.4"},
+		{parser: "json", fixture: "report.json", opts: options{"path": "totals.passed"}, want: "true"},
+		{parser: "json", fixture: "report.json", opts: options{"path": "results.1.status"}, want: "flaky"},
+		{parser: "json", fixture: "report.json", opts: options{"path": "missing"}, want: "null"},
+		{parser: "json", fixture: "report.json", opts: options{"path": "results.2.status"}, wantErr: true},
+		{parser: "json", fixture: "report.json", opts: options{"path": "totals.lines"}, wantErr: true},
+		{parser: "json", fixture: "report.json", wantErr: true},
+		{parser: "junit", fixture: "junit-flat.xml", want: "8 passed, 2 failed"},
+		{parser: "junit", fixture: "junit-flat.xml", opts: options{"metric": "tests"}, want: "12"},
+		{parser: "junit", fixture: "junit-flat.xml", opts: options{"metric": "passed"}, want: "8"},
+		{parser: "junit", fixture: "junit-flat.xml", opts: options{"metric": "failed"}, want: "2"},
+		{parser: "junit", fixture: "junit-flat.xml", opts: options{"metric": "skipped"}, want: "2"},
+		{parser: "junit", fixture: "junit-flat.xml", opts: options{"metric": "flaky"}, wantErr: true},
+		{parser: "junit", fixture: "junit-nested.xml", want: "8 passed, 1 failed"},
+		{parser: "junit", fixture: "junit-nested.xml", opts: options{"metric": "tests"}, want: "10"},
+		{parser: "junit", fixture: "junit-passing.xml", want: "4 passed"},
+		{parser: "junit", fixture: "plain.txt", wantErr: true},
+		{parser: "lcov", fixture: "lcov.info", want: "87.5%"},
+		{parser: "lcov", fixture: "lcov.info", opts: options{"metric": "branch"}, want: "50%"},
+		{parser: "lcov", fixture: "plain.txt", wantErr: true},
+		{parser: "cobertura", fixture: "cobertura.xml", want: "87.34%"},
+		{parser: "cobertura", fixture: "cobertura.xml", opts: options{"metric": "branch"}, want: "50%"},
+		{parser: "cobertura", fixture: "junit-flat.xml", wantErr: true},
+		{parser: "benchstat", fixture: "benchstat.txt", want: "-4.77%"},
+		{parser: "benchstat", fixture: "benchstat.txt", opts: options{"benchmark": "SVG-8"}, want: "-9.71%"},
+		{parser: "benchstat", fixture: "benchstat.txt", opts: options{"benchmark": "PNG-8"}, want: "~"},
+		{parser: "benchstat", fixture: "benchstat.txt", opts: options{"benchmark": "GIF-8"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		p, ok := Lookup(tt.parser)
+		if !ok {
+			t.Fatalf("parser %s not registered", tt.parser)
+		}
+		got, err := p.Parse(readFixture(t, tt.fixture), tt.opts)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%s %s %v: got %q, want error", tt.parser, tt.fixture, tt.opts, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s %s %v: %v", tt.parser, tt.fixture, tt.opts, err)
+		} else if got != tt.want {
+			t.Errorf("%s %s %v: got %q, want %q", tt.parser, tt.fixture, tt.opts, got, tt.want)
+		}
+	}
+}
+
+func TestRegister(t *testing.T) {
+	want := []string{"benchstat", "cobertura", "json", "junit", "lcov", "plain"}
+	names := Names()
+	if len(names) != len(want) {
+		t.Fatalf("got parsers %v, want %v", names, want)
+	}
+	for i := range want {
+		if names[i] != want[i] {
+			t.Fatalf("got parsers %v, want %v", names, want)
+		}
+	}
+	defer func() {
+		if recover() == nil {
+			t.Error("registering a duplicate name did not panic")
+		}
+	}()
+	Register("plain", ParserFunc(parsePlain))
+}
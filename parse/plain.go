@@ -0,0 +1,21 @@
+package parse
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+)
+
+// parsePlain returns the first non-empty line.
+func parsePlain(data []byte, opts Options) (string, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			return line, nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	return "", errNoValue("plain")
+}
@@ -0,0 +1,232 @@
+package badge
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"strconv"
+	"strings"
+)
+
+const pngContentType = "image/png"
+
+// PNG renders the badge as a PNG image using the built-in bitmap font.
+// Icons are not drawn and running badges are not animated.
+func (b *Badge) PNG() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, b.rasterImage()); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// rasterImage draws the badge as PNG renders it.
+func (b *Badge) rasterImage() *image.NRGBA {
+	style := b.style()
+	segments := b.segments()
+	widths := make([]int, len(segments))
+	for i, seg := range segments {
+		widths[i] = rasterTextWidth(seg.text) + 2*style.padding
+		if seg.spark != nil {
+			widths[i] = sparkWidth + 2*style.padding
+		}
+		if seg.pulse {
+			widths[i] += pulseSize + iconGap
+		}
+	}
+	// Bitmap text can't be squeezed, so status text is cut to fit.
+	statusWidth := rasterTextWidth(segments[1].text)
+	if shrink := b.layoutWidths(widths, statusWidth-glyphWidth); shrink > 0 {
+		segments[1].text = truncateMiddle(segments[1].text, (statusWidth-shrink+1)/glyphAdvance)
+	}
+	width := 0
+	for _, w := range widths {
+		width += w
+	}
+	height := style.height
+
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	x := 0
+	for i, seg := range segments {
+		fillGradient(img, image.Rect(x, 0, x+widths[i], height), parseHexColor(seg.color), parseHexColor(seg.colorTo))
+		x += widths[i]
+	}
+	if len(style.gradient) > 0 {
+		shadeGradient(img)
+	}
+
+	top := (height - glyphHeight) / 2
+	shadow := color.NRGBA{0x01, 0x01, 0x01, 0x4d}
+	white := color.NRGBA{0xff, 0xff, 0xff, 0xff}
+	x = 0
+	for i, seg := range segments {
+		if seg.spark != nil {
+			drawSparkline(img, x+style.padding, 4, height-8, seg.spark, white)
+			x += widths[i]
+			continue
+		}
+		left := x + style.padding
+		if seg.pulse {
+			drawDot(img, left, height/2, white)
+			left += pulseSize + iconGap
+		}
+		// Center text in segments widened to a layout width.
+		left += (x + widths[i] - style.padding - left - rasterTextWidth(seg.text)) / 2
+		if style.shadow {
+			drawText(img, left, top+1, seg.text, shadow)
+		}
+		drawText(img, left, top, seg.text, white)
+		x += widths[i]
+	}
+	roundCorners(img, style.radius)
+	return scaleImage(img, b.scaled(width), b.scaled(height))
+}
+
+// rasterTextWidth returns the width of text drawn with the bitmap font.
+func rasterTextWidth(text string) int {
+	n := len(visibleRunes(text))
+	if n == 0 {
+		return 0
+	}
+	return n*glyphAdvance - 1
+}
+
+// drawText draws text with its top left corner at x, y.
+func drawText(img *image.NRGBA, x, y int, text string, c color.NRGBA) {
+	for _, r := range visibleRunes(text) {
+		g := glyph(r)
+		for col, bits := range g {
+			for row := 0; row < glyphHeight; row++ {
+				if bits&(1<<uint(row)) != 0 {
+					blendPixel(img, x+col, y+row, c)
+				}
+			}
+		}
+		x += glyphAdvance
+	}
+}
+
+// drawSparkline draws the line chart of values into the box at x, y.
+func drawSparkline(img *image.NRGBA, x, y, height int, values []float64, c color.NRGBA) {
+	points := sparkPoints(values, sparkWidth, float64(height))
+	for i := 1; i < len(points); i++ {
+		x0, y0 := float64(x)+points[i-1][0], float64(y)+points[i-1][1]
+		x1, y1 := float64(x)+points[i][0], float64(y)+points[i][1]
+		steps := int(math.Max(math.Abs(x1-x0), math.Abs(y1-y0))) + 1
+		for s := 0; s <= steps; s++ {
+			t := float64(s) / float64(steps)
+			blendPixel(img, int(x0+(x1-x0)*t+0.5), int(y0+(y1-y0)*t+0.5), c)
+		}
+	}
+}
+
+// drawDot draws the static running indicator, left aligned at x.
+func drawDot(img *image.NRGBA, x, cy int, c color.NRGBA) {
+	r := float64(pulseSize) / 2
+	for dy := 0; dy < pulseSize; dy++ {
+		for dx := 0; dx < pulseSize; dx++ {
+			if math.Hypot(float64(dx)+0.5-r, float64(dy)+0.5-r) <= r {
+				blendPixel(img, x+dx, cy-pulseSize/2+dy, c)
+			}
+		}
+	}
+}
+
+// fillRect paints rect opaquely with c.
+func fillRect(img *image.NRGBA, rect image.Rectangle, c color.NRGBA) {
+	rect = rect.Intersect(img.Bounds())
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			img.SetNRGBA(x, y, c)
+		}
+	}
+}
+
+// fillGradient paints rect opaquely, fading from c0 on the left to c1 on the right.
+func fillGradient(img *image.NRGBA, rect image.Rectangle, c0, c1 color.NRGBA) {
+	if c0 == c1 || rect.Dx() < 2 {
+		fillRect(img, rect, c0)
+		return
+	}
+	lerp := func(a, b uint8, t float64) uint8 {
+		return uint8(float64(a) + (float64(b)-float64(a))*t + 0.5)
+	}
+	for x := rect.Min.X; x < rect.Max.X; x++ {
+		t := float64(x-rect.Min.X) / float64(rect.Dx()-1)
+		c := color.NRGBA{lerp(c0.R, c1.R, t), lerp(c0.G, c1.G, t), lerp(c0.B, c1.B, t), lerp(c0.A, c1.A, t)}
+		fillRect(img, image.Rect(x, rect.Min.Y, x+1, rect.Max.Y), c)
+	}
+}
+
+// shadeGradient overlays the subtle top-to-bottom gradient of flat badges.
+func shadeGradient(img *image.NRGBA) {
+	bounds := img.Bounds()
+	height := bounds.Dy()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		t := float64(y-bounds.Min.Y) / float64(height-1)
+		gray := uint8(0xbb * (1 - t))
+		c := color.NRGBA{gray, gray, gray, 0x1a}
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			blendPixel(img, x, y, c)
+		}
+	}
+}
+
+// roundCorners makes the corners of img transparent outside of radius r.
+func roundCorners(img *image.NRGBA, r int) {
+	bounds := img.Bounds()
+	radius := float64(r)
+	for y := 0; y < r; y++ {
+		for x := 0; x < r; x++ {
+			// Distance of the pixel center from the corner circle's center.
+			dx, dy := radius-float64(x)-0.5, radius-float64(y)-0.5
+			coverage := math.Max(0, math.Min(1, radius-math.Hypot(dx, dy)+0.5))
+			for _, p := range []image.Point{
+				{bounds.Min.X + x, bounds.Min.Y + y},
+				{bounds.Max.X - 1 - x, bounds.Min.Y + y},
+				{bounds.Min.X + x, bounds.Max.Y - 1 - y},
+				{bounds.Max.X - 1 - x, bounds.Max.Y - 1 - y},
+			} {
+				c := img.NRGBAAt(p.X, p.Y)
+				c.A = uint8(float64(c.A) * coverage)
+				img.SetNRGBA(p.X, p.Y, c)
+			}
+		}
+	}
+}
+
+// blendPixel composites c over the opaque pixel at x, y.
+func blendPixel(img *image.NRGBA, x, y int, c color.NRGBA) {
+	if !(image.Point{x, y}.In(img.Bounds())) {
+		return
+	}
+	dst := img.NRGBAAt(x, y)
+	a := uint32(c.A)
+	mix := func(s, d uint8) uint8 {
+		return uint8((uint32(s)*a + uint32(d)*(255-a)) / 255)
+	}
+	img.SetNRGBA(x, y, color.NRGBA{mix(c.R, dst.R), mix(c.G, dst.G), mix(c.B, dst.B), dst.A})
+}
+
+// parseHexColor parses a color as returned by resolveColor.
+func parseHexColor(hex string) color.NRGBA {
+	hex = strings.TrimPrefix(hex, "#")
+	if len(hex) == 3 || len(hex) == 4 {
+		var long strings.Builder
+		for _, c := range hex {
+			long.WriteRune(c)
+			long.WriteRune(c)
+		}
+		hex = long.String()
+	}
+	if len(hex) == 6 {
+		hex += "ff"
+	}
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil || len(hex) != 8 {
+		return color.NRGBA{0x00, 0x88, 0xcc, 0xff}
+	}
+	return color.NRGBA{uint8(v >> 24), uint8(v >> 16), uint8(v >> 8), uint8(v)}
+}
@@ -0,0 +1,48 @@
+package badge
+
+import "regexp"
+
+// redactedText replaces redacted parts of a resolved value.
+const redactedText = "[redacted]"
+
+// defaultRedactPatterns match strings that should never reach a badge.
+var defaultRedactPatterns = []string{
+	// GitHub tokens.
+	`\bgh[pousr]_[A-Za-z0-9]{20,}\b`,
+	`\bgithub_pat_[A-Za-z0-9_]{20,}\b`,
+	// JSON Web Tokens.
+	`\beyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+`,
+	// AWS access key IDs.
+	`\b(AKIA|ASIA)[A-Z0-9]{16}\b`,
+	// Email addresses.
+	`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`,
+}
+
+// redactions are the compiled redaction patterns in effect.
+var redactions []*regexp.Regexp
+
+// compileRedactions compiles the default patterns, if enabled, and the extra ones.
+func compileRedactions(defaults bool, extra []string) ([]*regexp.Regexp, error) {
+	var patterns []string
+	if defaults {
+		patterns = append(patterns, defaultRedactPatterns...)
+	}
+	patterns = append(patterns, extra...)
+	compiled := make([]*regexp.Regexp, 0, len(patterns))
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, err
+		}
+		compiled = append(compiled, re)
+	}
+	return compiled, nil
+}
+
+// redact replaces all matches of the redaction patterns in a value.
+func redact(value string) string {
+	for _, re := range redactions {
+		value = re.ReplaceAllString(value, redactedText)
+	}
+	return value
+}
@@ -0,0 +1,284 @@
+package badge
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Renderer turns badges into responses.
+type Renderer interface {
+	// Render renders the badge in the given output format:
+	// "svg" (also the empty format), "png", "webp" or "json".
+	// Renderers fetching badges stop when ctx is done.
+	Render(ctx context.Context, b *Badge, format string) (*Rendering, error)
+}
+
+// Rendering is a rendered badge, either an image or a link to one.
+type Rendering struct {
+	// Location is the URL of an externally rendered image.
+	// If set, the response redirects there instead of serving Body.
+	Location    string
+	ContentType string
+	Body        []byte
+}
+
+// status returns the HTTP status of the response.
+func (r *Rendering) status() int {
+	if r.Location != "" {
+		return http.StatusSeeOther
+	}
+	return http.StatusOK
+}
+
+// setHeaders sets the headers describing the response.
+func (r *Rendering) setHeaders(h http.Header) {
+	if r.Location != "" {
+		h.Set("Location", r.Location)
+		return
+	}
+	h.Set("Content-Type", r.ContentType)
+	h.Set("Content-Length", strconv.Itoa(len(r.Body)))
+}
+
+// renderers create the renderers selectable in the config.
+// baseURL is the service of redirecting renderers, the default if empty.
+var renderers = map[string]func(baseURL string) Renderer{
+	"native": func(string) Renderer {
+		return nativeRenderer{}
+	},
+	"badgen": func(baseURL string) Renderer {
+		if baseURL == "" {
+			baseURL = "https://badgen.net"
+		}
+		return badgenRenderer{baseURL: baseURL}
+	},
+	"shields": func(baseURL string) Renderer {
+		if baseURL == "" {
+			baseURL = "https://img.shields.io"
+		}
+		return shieldsRenderer{baseURL: baseURL}
+	},
+}
+
+// defaultRenderer is used unless configured otherwise. Badgen stays the
+// default since the native renderer only knows some of its icons.
+const defaultRenderer = "badgen"
+
+// renderer renders all badges.
+var renderer = renderers[defaultRenderer]("")
+
+// nativeRenderer renders badges in-process.
+type nativeRenderer struct{}
+
+func (nativeRenderer) Render(ctx context.Context, b *Badge, format string) (*Rendering, error) {
+	switch format {
+	case "png":
+		body, err := b.PNG()
+		if err != nil {
+			return nil, err
+		}
+		return &Rendering{ContentType: pngContentType, Body: body}, nil
+	case "webp":
+		body, err := b.WebP()
+		if err != nil {
+			return nil, err
+		}
+		return &Rendering{ContentType: webpContentType, Body: body}, nil
+	case "json":
+		body, err := b.EndpointJSON()
+		if err != nil {
+			return nil, err
+		}
+		return &Rendering{ContentType: jsonContentType, Body: body}, nil
+	default:
+		return &Rendering{ContentType: svgContentType, Body: b.SVG()}, nil
+	}
+}
+
+// badgenRenderer redirects to SVG badges of a badgen service.
+// Other formats are rendered natively.
+type badgenRenderer struct {
+	baseURL string
+}
+
+func (r badgenRenderer) Render(ctx context.Context, b *Badge, format string) (*Rendering, error) {
+	if format != "" && format != "svg" {
+		return nativeRenderer{}.Render(ctx, b, format)
+	}
+	subject, status := b.flatTexts()
+	values := make(url.Values)
+	if b.Color != "" {
+		values.Set("color", firstColor(b.Color))
+	}
+	if b.Logo != "" {
+		values.Set("icon", b.Logo)
+	} else if b.Icon != "" {
+		values.Set("icon", b.Icon)
+	}
+	if style := badgenStyle(b.Style); style != "" {
+		values.Set("style", style)
+	}
+	if scale := b.scale(); scale != 1 {
+		values.Set("scale", strconv.FormatFloat(scale, 'f', -1, 64))
+	}
+	return &Rendering{Location: fmt.Sprintf("%s/badge/%s/%s?%s", r.baseURL,
+		url.PathEscape(subject), url.PathEscape(status), values.Encode())}, nil
+}
+
+// shieldsRenderer redirects to SVG badges of a shields.io service.
+// Other formats and scaled badges, which shields.io can't size, are rendered natively.
+type shieldsRenderer struct {
+	baseURL string
+}
+
+func (r shieldsRenderer) Render(ctx context.Context, b *Badge, format string) (*Rendering, error) {
+	if format != "" && format != "svg" || b.scale() != 1 {
+		return nativeRenderer{}.Render(ctx, b, format)
+	}
+	subject, status := b.flatTexts()
+	values := url.Values{
+		"label":   {subject},
+		"message": {status},
+	}
+	if b.Color != "" {
+		values.Set("color", firstColor(b.Color))
+	}
+	if b.Logo != "" {
+		values.Set("logo", b.Logo)
+	} else if b.Icon != "" {
+		values.Set("logo", b.Icon)
+	}
+	if b.Style != "" {
+		values.Set("style", b.Style)
+	}
+	return &Rendering{Location: r.baseURL + "/static/v1?" + values.Encode()}, nil
+}
+
+// proxyTimeout bounds fetches of externally rendered badges.
+const proxyTimeout = 10 * time.Second
+
+// maxProxyResponse caps externally rendered badges.
+const maxProxyResponse = 256 << 10
+
+// proxyRetryAfter is how long badges are rendered natively
+// after the external renderer failed.
+const proxyRetryAfter = 30 * time.Second
+
+// proxyLookups shares fetches of identical externally rendered badges.
+var proxyLookups = newCoalescer(renderCacheTTL)
+
+// proxyHealth tracks the external renderer of proxyRenderer.
+var proxyHealth rendererHealth
+
+// proxyRenderer serves the images of a redirecting renderer itself
+// instead of redirecting clients to them. While the external renderer
+// fails or times out, badges are rendered natively instead.
+type proxyRenderer struct {
+	next Renderer
+}
+
+func (r proxyRenderer) Render(ctx context.Context, b *Badge, format string) (*Rendering, error) {
+	rendering, err := r.next.Render(ctx, b, format)
+	if err != nil || rendering.Location == "" {
+		return rendering, err
+	}
+	if !proxyHealth.up(time.Now()) {
+		proxyHealth.failover()
+		return nativeRenderer{}.Render(ctx, b, format)
+	}
+	// The shared fetch stops once all waiting requests are gone,
+	// and is bounded on its own in case new ones keep joining.
+	fetchCtx, cancel := context.WithTimeout(ctx, proxyTimeout)
+	defer cancel()
+	val, err := proxyLookups.do(fetchCtx, rendering.Location, func(ctx context.Context) (interface{}, error) {
+		ctx, cancel := context.WithTimeout(ctx, proxyTimeout)
+		defer cancel()
+		return fetchRendering(ctx, rendering.Location)
+	})
+	if err != nil {
+		if ctx.Err() != nil {
+			// The request is gone, which says nothing about the renderer.
+			return nil, fmt.Errorf("failed to fetch badge: %w", err)
+		}
+		log.Printf("External renderer failed, rendering natively: %v", err)
+		proxyHealth.fail(time.Now(), err)
+		proxyHealth.failover()
+		return nativeRenderer{}.Render(ctx, b, format)
+	}
+	return val.(*Rendering), nil
+}
+
+// rendererHealth tracks failures of an external renderer.
+type rendererHealth struct {
+	mu        sync.Mutex
+	downUntil time.Time
+	lastErr   error
+	failovers int64
+}
+
+// up reports whether the renderer is to be used at the time now.
+func (h *rendererHealth) up(now time.Time) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return !now.Before(h.downUntil)
+}
+
+// fail marks the renderer as failed by err at the time now.
+func (h *rendererHealth) fail(now time.Time, err error) {
+	h.mu.Lock()
+	h.downUntil = now.Add(proxyRetryAfter)
+	h.lastErr = err
+	h.mu.Unlock()
+}
+
+// failover counts a badge rendered natively in place of the renderer.
+func (h *rendererHealth) failover() {
+	h.mu.Lock()
+	h.failovers++
+	h.mu.Unlock()
+}
+
+// String summarizes the health for the status page.
+func (h *rendererHealth) String() string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	summary := fmt.Sprintf("%d failovers", h.failovers)
+	if time.Now().Before(h.downUntil) {
+		summary += fmt.Sprintf(", rendering natively until %s after: %v",
+			h.downUntil.UTC().Format(time.RFC3339), h.lastErr)
+	}
+	return summary
+}
+
+// fetchRendering downloads an externally rendered image.
+func fetchRendering(ctx context.Context, location string) (*Rendering, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, location, nil)
+	if err != nil {
+		return nil, err
+	}
+	res, err := downloadClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("status %s", res.Status)
+	}
+	contentType := res.Header.Get("Content-Type")
+	if !strings.HasPrefix(contentType, "image/") {
+		return nil, fmt.Errorf("unexpected content type %q", contentType)
+	}
+	var buf bytes.Buffer
+	if err := readCapped(&buf, &contextReader{ctx, res.Body}, maxProxyResponse); err != nil {
+		return nil, err
+	}
+	return &Rendering{ContentType: contentType, Body: buf.Bytes()}, nil
+}
@@ -0,0 +1,156 @@
+package badge
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// renderCacheTTL is how long a rendered badge is replayed
+// unless a fresh resolution of its source invalidates it first.
+const renderCacheTTL = time.Minute
+
+// renderCacheSize caps the number of cached renders. Once reached, expired
+// renders are dropped, and arbitrary ones if the cache stays full.
+const renderCacheSize = 4096
+
+// renderedBadge is a complete badge response ready to be replayed.
+type renderedBadge struct {
+	header  http.Header
+	status  int
+	body    []byte
+	expires time.Time
+	// source identifies the artifact query the badge was resolved from,
+	// value the resolved artifact and status.
+	source string
+	value  string
+}
+
+// writeTo writes the response without rebuilding headers or the badge.
+// Header values are copied, so that changes to the response headers
+// don't reach the cached entry.
+func (b *renderedBadge) writeTo(w http.ResponseWriter) {
+	h := w.Header()
+	for key, values := range b.header {
+		h[key] = append([]string(nil), values...)
+	}
+	w.WriteHeader(b.status)
+	w.Write(b.body)
+}
+
+// renderCache holds rendered badges per unique request query.
+type renderCache struct {
+	mu      sync.RWMutex
+	ttl     time.Duration
+	entries map[string]*renderedBadge
+	// bySource holds the keys of the renders of each source.
+	bySource map[string]map[string]bool
+}
+
+func newRenderCache(ttl time.Duration) *renderCache {
+	return &renderCache{
+		ttl:      ttl,
+		entries:  make(map[string]*renderedBadge),
+		bySource: make(map[string]map[string]bool),
+	}
+}
+
+// get returns the unexpired render for key, or nil.
+func (c *renderCache) get(key string) *renderedBadge {
+	c.mu.RLock()
+	entry := c.entries[key]
+	c.mu.RUnlock()
+	if entry == nil || time.Now().After(entry.expires) {
+		return nil
+	}
+	return entry
+}
+
+// put stores a render of the given response, resolved from value of source.
+func (c *renderCache) put(key, source, value string, header http.Header, status int, body []byte) *renderedBadge {
+	entry := &renderedBadge{
+		header:  header.Clone(),
+		status:  status,
+		body:    body,
+		expires: time.Now().Add(c.ttl),
+		source:  source,
+		value:   value,
+	}
+	c.mu.Lock()
+	c.remove(key)
+	if len(c.entries) >= renderCacheSize {
+		c.purge()
+	}
+	c.entries[key] = entry
+	keys := c.bySource[source]
+	if keys == nil {
+		keys = make(map[string]bool)
+		c.bySource[source] = keys
+	}
+	keys[key] = true
+	c.mu.Unlock()
+	return entry
+}
+
+// invalidate drops renders of source that were made from a value other than value.
+func (c *renderCache) invalidate(source, value string) {
+	c.mu.RLock()
+	stale := false
+	for key := range c.bySource[source] {
+		if c.entries[key].value != value {
+			stale = true
+			break
+		}
+	}
+	c.mu.RUnlock()
+	if !stale {
+		return
+	}
+	c.mu.Lock()
+	for key := range c.bySource[source] {
+		if c.entries[key].value != value {
+			c.remove(key)
+		}
+	}
+	c.mu.Unlock()
+}
+
+// purge drops expired renders, then arbitrary ones until there is room.
+// The caller must hold c.mu for writing.
+func (c *renderCache) purge() {
+	now := time.Now()
+	for key, entry := range c.entries {
+		if now.After(entry.expires) {
+			c.remove(key)
+		}
+	}
+	for key := range c.entries {
+		if len(c.entries) < renderCacheSize {
+			break
+		}
+		c.remove(key)
+	}
+}
+
+// remove drops the render stored under key, if any.
+// The caller must hold c.mu for writing.
+func (c *renderCache) remove(key string) {
+	entry, ok := c.entries[key]
+	if !ok {
+		return
+	}
+	delete(c.entries, key)
+	if keys := c.bySource[entry.source]; keys != nil {
+		delete(keys, key)
+		if len(keys) == 0 {
+			delete(c.bySource, entry.source)
+		}
+	}
+}
+
+// size returns the number of cached renders, stale ones included.
+func (c *renderCache) size() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return len(c.entries)
+}
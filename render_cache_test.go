@@ -0,0 +1,146 @@
+package badge
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestRenderedBadgeHeaderCopies(t *testing.T) {
+	c := newRenderCache(time.Minute)
+	header := http.Header{"Content-Type": {svgContentType}}
+	entry := c.put("key", "source", "value", header, http.StatusOK, []byte("<svg/>"))
+	header.Add("Content-Type", "changed after put")
+
+	w := httptest.NewRecorder()
+	entry.writeTo(w)
+	w.Header().Add("Content-Type", "changed after write")
+	w.Header()["Content-Type"][0] = "overwritten"
+
+	if got := c.get("key").header["Content-Type"]; len(got) != 1 || got[0] != svgContentType {
+		t.Errorf("cached header changed to %q", got)
+	}
+	if w.Body.String() != "<svg/>" || w.Code != http.StatusOK {
+		t.Errorf("got %d %q", w.Code, w.Body.String())
+	}
+}
+
+func TestRenderCacheTTL(t *testing.T) {
+	c := newRenderCache(time.Minute)
+	c.put("key", "source", "value", http.Header{}, http.StatusOK, nil)
+	if c.get("key") == nil {
+		t.Fatal("fresh entry: got nil")
+	}
+	c.entries["key"].expires = time.Now().Add(-time.Second)
+	if c.get("key") != nil {
+		t.Error("expired entry: got a hit")
+	}
+	if c.get("missing") != nil {
+		t.Error("missing entry: got a hit")
+	}
+}
+
+func TestRenderCacheCap(t *testing.T) {
+	c := newRenderCache(time.Minute)
+	put := func(i int) {
+		key := strconv.Itoa(i)
+		c.put(key, "source"+strconv.Itoa(i%10), "value", http.Header{}, http.StatusOK, nil)
+	}
+	for i := 0; i < renderCacheSize; i++ {
+		put(i)
+	}
+	// Expired entries go first once the cache is full.
+	c.entries["7"].expires = time.Now().Add(-time.Second)
+	put(renderCacheSize)
+	if c.size() != renderCacheSize {
+		t.Errorf("got %d entries, want %d", c.size(), renderCacheSize)
+	}
+	if _, ok := c.entries["7"]; ok {
+		t.Error("the expired entry was kept")
+	}
+	for i := renderCacheSize + 1; i < 2*renderCacheSize; i++ {
+		put(i)
+	}
+	if c.size() > renderCacheSize {
+		t.Errorf("got %d entries, want at most %d", c.size(), renderCacheSize)
+	}
+	checkSourceIndex(t, c)
+}
+
+func TestRenderCacheReplace(t *testing.T) {
+	c := newRenderCache(time.Minute)
+	c.put("key", "old source", "value", http.Header{}, http.StatusOK, nil)
+	c.put("key", "new source", "value", http.Header{}, http.StatusOK, nil)
+	if _, ok := c.bySource["old source"]; ok {
+		t.Error("the replaced entry is still indexed by its source")
+	}
+	checkSourceIndex(t, c)
+}
+
+// checkSourceIndex checks that bySource indexes exactly the cached entries.
+func checkSourceIndex(t *testing.T, c *renderCache) {
+	t.Helper()
+	indexed := 0
+	for source, keys := range c.bySource {
+		if len(keys) == 0 {
+			t.Errorf("%s: empty index kept", source)
+		}
+		for key := range keys {
+			indexed++
+			if entry, ok := c.entries[key]; !ok || entry.source != source {
+				t.Errorf("%s: indexes %s, which is not cached for it", source, key)
+			}
+		}
+	}
+	if indexed != len(c.entries) {
+		t.Errorf("%d entries indexed, want %d", indexed, len(c.entries))
+	}
+}
+
+func TestRenderCacheInvalidate(t *testing.T) {
+	c := newRenderCache(time.Minute)
+	c.put("svg", "run", "old", http.Header{}, http.StatusOK, nil)
+	c.put("png", "run", "old", http.Header{}, http.StatusOK, nil)
+	c.put("current", "run", "new", http.Header{}, http.StatusOK, nil)
+	c.put("other", "other run", "old", http.Header{}, http.StatusOK, nil)
+
+	c.invalidate("run", "new")
+	for key, want := range map[string]bool{"svg": false, "png": false, "current": true, "other": true} {
+		if got := c.get(key) != nil; got != want {
+			t.Errorf("%s: cached %v, want %v", key, got, want)
+		}
+	}
+	checkSourceIndex(t, c)
+
+	c.invalidate("run", "newer")
+	c.invalidate("unknown", "value")
+	if c.get("current") != nil || c.size() != 1 {
+		t.Errorf("got %d entries, want only the other run", c.size())
+	}
+	checkSourceIndex(t, c)
+}
+
+func TestMaintenanceBypassesRenderCache(t *testing.T) {
+	setupOnce.Do(func() {})
+	defer func(cfg *Config) { config = cfg }(config)
+	config = &Config{}
+	const query = "subject=build&repo=org/repo&run=CI&badge=status&branch=main"
+	renders.put(query, "source", "value", http.Header{"X-Cached": {"1"}}, http.StatusOK, []byte("<svg/>"))
+	defer renders.invalidate("source", "value")
+
+	serve := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		GenBadgeHTTP(w, httptest.NewRequest("GET", "/?"+query, nil))
+		return w
+	}
+	if w := serve(); w.Header().Get("X-Cached") != "1" {
+		t.Fatalf("before maintenance: got %v, want cache hit", w.Header())
+	}
+	config.Maintenance = "upgrading"
+	w := serve()
+	if w.Header().Get("X-Cached") != "" || w.Header().Get("X-AB-Maintenance") != "1" {
+		t.Errorf("during maintenance: got %v, want maintenance badge", w.Header())
+	}
+}
@@ -0,0 +1,101 @@
+package badge
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestProxyRendererFailover(t *testing.T) {
+	var fail int32
+	var hits int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		if atomic.LoadInt32(&fail) != 0 {
+			http.Error(w, "down", http.StatusBadGateway)
+			return
+		}
+		w.Header().Set("Content-Type", "image/svg+xml")
+		w.Write([]byte("<svg/>"))
+	}))
+	defer srv.Close()
+	defer func() { proxyHealth = rendererHealth{} }()
+	proxyHealth = rendererHealth{}
+	r := proxyRenderer{next: badgenRenderer{baseURL: srv.URL}}
+	ctx := context.Background()
+
+	render := func(status string) *Rendering {
+		t.Helper()
+		rendering, err := r.Render(ctx, &Badge{Subject: "failover", Status: status}, "")
+		if err != nil {
+			t.Fatalf("%s: %v", status, err)
+		}
+		return rendering
+	}
+	if got := render("up"); string(got.Body) != "<svg/>" {
+		t.Errorf("healthy: got %q, want the external render", got.Body)
+	}
+
+	atomic.StoreInt32(&fail, 1)
+	if got := render("failing"); got.ContentType != svgContentType || string(got.Body) == "<svg/>" {
+		t.Errorf("failing: got %s %q, want a native render", got.ContentType, got.Body)
+	}
+	before := atomic.LoadInt32(&hits)
+	render("down")
+	if atomic.LoadInt32(&hits) != before {
+		t.Error("down: the external renderer was called")
+	}
+	if proxyHealth.failovers != 2 {
+		t.Errorf("got %d failovers, want 2", proxyHealth.failovers)
+	}
+
+	// Once the retry delay passed, the external renderer is tried again.
+	atomic.StoreInt32(&fail, 0)
+	proxyHealth.downUntil = time.Now().Add(-time.Second)
+	if got := render("recovered"); string(got.Body) != "<svg/>" {
+		t.Errorf("recovered: got %q, want the external render", got.Body)
+	}
+}
+
+func TestProxyRendererCanceled(t *testing.T) {
+	block := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-block
+	}))
+	defer srv.Close()
+	defer close(block)
+	defer func() { proxyHealth = rendererHealth{} }()
+	proxyHealth = rendererHealth{}
+	r := proxyRenderer{next: badgenRenderer{baseURL: srv.URL}}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := r.Render(ctx, &Badge{Subject: "canceled", Status: "gone"}, ""); err == nil {
+		t.Error("got no error for a canceled request")
+	}
+	if !proxyHealth.up(time.Now()) || proxyHealth.failovers != 0 {
+		t.Error("a canceled request marked the renderer as failed")
+	}
+}
+
+func TestNativeUnknownIcon(t *testing.T) {
+	for _, icon := range []string{"npm", "docker"} {
+		b := &Badge{Subject: "build", Status: "passing", Color: "green", Icon: icon}
+		rendering, err := nativeRenderer{}.Render(context.Background(), b, "svg")
+		if err != nil {
+			t.Fatalf("%s: %v", icon, err)
+		}
+		if body := string(rendering.Body); !strings.Contains(body, "passing") || strings.Contains(body, "<image") {
+			t.Errorf("%s: got %s, want badge without icon", icon, body)
+		}
+	}
+	b := &Badge{Subject: "build", Status: "passing", Color: "green", Icon: "GitHub"}
+	rendering, _ := nativeRenderer{}.Render(context.Background(), b, "svg")
+	if !strings.Contains(string(rendering.Body), "<image") {
+		t.Errorf("github: got %s, want badge with icon", rendering.Body)
+	}
+}
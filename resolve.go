@@ -0,0 +1,462 @@
+package badge
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bradleyfalzon/ghinstallation"
+	"github.com/google/go-github/v37/github"
+
+	"github.com/terorie/action-badge/parse"
+)
+
+// errNotInstalled is returned for repos the App is not installed on.
+var errNotInstalled = errors.New("Can't find installation for repo")
+
+// Errors of strict queries matching several candidates.
+var (
+	errAmbiguousRun      = errors.New("Several workflows match run name")
+	errAmbiguousArtifact = errors.New("Several artifacts match badge name")
+)
+
+// notInstalledText is the status shown for repos without the App.
+const notInstalledText = "app not installed"
+
+// Workflow run statuses looked up by badges.
+const (
+	runSuccess    = "success"
+	runInProgress = "in_progress"
+)
+
+// badgeQuery identifies the artifact a badge is resolved from.
+type badgeQuery struct {
+	// Host is the GHES instance of the repo, empty for github.com.
+	Host       string
+	Owner      string
+	Repo       string
+	Branch     string
+	Run        string
+	Badge      string
+	ArtifactID int64
+	// Parse names the parser extracting the status from the artifact file,
+	// empty to detect it from the content. Plain text yields its first line.
+	Parse string
+	// Upstream retries failed lookups against the parent of a forked repo.
+	Upstream bool
+	// Strict fails lookups matching several workflows, artifacts
+	// or artifact files instead of picking one.
+	Strict bool
+	// Source names an external source to resolve from instead of artifacts.
+	Source string
+	// Params holds all request params, for sources needing more than the repo.
+	Params url.Values
+	// allowPrivate permits resolving badges of private repos.
+	allowPrivate bool
+}
+
+// resolution is the outcome of resolving a badge query.
+type resolution struct {
+	// Run is the run the artifact belongs to, nil if resolved by artifact ID.
+	Run        *github.WorkflowRun
+	ArtifactID int64
+	Status     string
+	ResolvedAt time.Time
+	// Private is set if the badge belongs to a private repo.
+	Private bool
+	// Unsafe is set if the status contained bidi control or invisible characters.
+	Unsafe bool
+	// Lines are the further non-empty lines of the artifact after Status.
+	Lines []string
+	// Empty is set if the artifact holds no value, in which case
+	// Status is the configured empty text.
+	Empty bool
+	// Running is set if the workflow has no successful run yet but is running,
+	// in which case Run is the running one and there is no artifact.
+	Running bool
+}
+
+// key identifies the artifact selection of a query.
+func (q *badgeQuery) key() string {
+	parts := []string{
+		q.Host, q.Owner, q.Repo, q.Branch, strings.ToLower(q.Run), q.Badge,
+		strconv.FormatInt(q.ArtifactID, 10), strconv.FormatBool(q.Upstream),
+		strconv.FormatBool(q.Strict),
+	}
+	if q.Source != "" {
+		// Sources may select by any param.
+		parts = append(parts, q.Source, q.Params.Encode())
+	} else if q.Parse != "" {
+		// Parsers may read any param as an option.
+		parts = append(parts, q.Parse, q.Params.Encode())
+	}
+	return strings.Join(parts, "\x00")
+}
+
+// key identifies the resolved artifact and text of a resolution.
+func (r *resolution) key() string {
+	return strconv.FormatInt(r.ArtifactID, 10) + "\x00" + r.Status + "\x00" + strings.Join(r.Lines, "\n")
+}
+
+// parseBadgeQuery decodes and checks the artifact selection params of a request.
+func parseBadgeQuery(r *http.Request) (*badgeQuery, error) {
+	query, err := decodeBadgeQuery(r)
+	if err != nil {
+		return nil, err
+	}
+	if query.ArtifactID == 0 && query.Source == "" && query.Branch == "" {
+		return nil, errors.New("Missing branch key")
+	}
+	return query, nil
+}
+
+// decodeBadgeQuery decodes the artifact selection params of a request,
+// leaving the branch optional.
+func decodeBadgeQuery(r *http.Request) (*badgeQuery, error) {
+	query := &badgeQuery{
+		Branch:   r.FormValue("branch"),
+		Run:      r.FormValue("run"),
+		Badge:    r.FormValue("badge"),
+		Upstream: boolParam(r, "upstream"),
+		Strict:   boolParam(r, "strict"),
+		Source:   r.FormValue("source"),
+		Parse:    r.FormValue("parse"),
+		Params:   r.Form,
+	}
+	// Sources check the repo themselves, some don't need one.
+	repoParam := r.FormValue("repo")
+	if repoParam == "" && query.Source == "" {
+		return nil, errors.New("Missing repo key")
+	}
+	if repoParam != "" {
+		host, repoParts, err := splitHostPrefix(repoParam, 2)
+		if err != nil {
+			return nil, err
+		}
+		if len(repoParts) != 2 || repoParts[0] == "" || repoParts[1] == "" {
+			return nil, errors.New("Invalid repo key")
+		}
+		query.Host, query.Owner, query.Repo = host, repoParts[0], repoParts[1]
+	}
+	if query.Source != "" {
+		return query, nil
+	}
+	if query.Parse != "" {
+		if _, ok := parse.Lookup(query.Parse); !ok {
+			return nil, errors.New("Unknown parser " + query.Parse)
+		}
+	}
+	if artifactParam := r.FormValue("artifactId"); artifactParam != "" {
+		artifactID, err := strconv.ParseInt(artifactParam, 10, 64)
+		if err != nil || artifactID <= 0 {
+			return nil, errors.New("Invalid artifactId key")
+		}
+		query.ArtifactID = artifactID
+	} else if artifactParam := r.FormValue("artifact"); artifactParam != "" {
+		artifactID, err := parseArtifactRef(artifactParam)
+		if err != nil {
+			return nil, errors.New("Invalid artifact key: " + err.Error())
+		}
+		query.ArtifactID = artifactID
+	}
+	if query.ArtifactID == 0 {
+		if query.Run == "" {
+			return nil, errors.New("Missing run key")
+		}
+		if query.Badge == "" {
+			return nil, errors.New("Missing badge key")
+		}
+	}
+	return query, nil
+}
+
+// resolve looks up the artifact selected by a query and extracts its status.
+func resolve(ctx context.Context, query *badgeQuery) (*resolution, error) {
+	if query.Source != "" {
+		return resolveSource(ctx, query)
+	}
+	res, err := resolveRepo(ctx, query)
+	if err == nil || !query.Upstream {
+		return res, err
+	}
+	// Retry against the upstream repo if this is a fork.
+	parent := forkParent(ctx, query.Host, query.Owner, query.Repo)
+	if parent == nil {
+		return nil, err
+	}
+	upstreamQuery := *query
+	upstreamQuery.Owner = parent.GetOwner().GetLogin()
+	upstreamQuery.Repo = parent.GetName()
+	return resolveRepo(ctx, &upstreamQuery)
+}
+
+// resolveRepo resolves a query against the repo it names.
+func resolveRepo(ctx context.Context, query *badgeQuery) (*resolution, error) {
+	repoClient, private, err := openRepo(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return resolveInRepo(ctx, repoClient, private, query)
+}
+
+// openRepo returns a client of the App installation of the repo of a query
+// and whether the repo is private, failing if the query may not read it.
+func openRepo(ctx context.Context, query *badgeQuery) (*github.Client, bool, error) {
+	repoClient, err := newRepoClient(ctx, query.Host, query.Owner, query.Repo)
+	if err != nil {
+		return nil, false, err
+	}
+	private, err := isPrivateRepo(ctx, repoClient, query.Owner, query.Repo)
+	if err != nil {
+		return nil, false, errors.New("Failed to get repo")
+	}
+	if private && !query.allowPrivate {
+		return nil, false, errors.New("Repo is private")
+	}
+	return repoClient, private, nil
+}
+
+// resolveInRepo resolves a query against its repo, opened by openRepo.
+func resolveInRepo(ctx context.Context, repoClient *github.Client, private bool, query *badgeQuery) (*resolution, error) {
+	res := &resolution{ArtifactID: query.ArtifactID, Private: private}
+	var err error
+	if res.ArtifactID == 0 {
+		// Find latest successful run matching run name.
+		res.Run, err = sharedRun(ctx, repoClient, query.Owner, query.Repo, query.Branch, query.Run, runSuccess, query.Strict)
+		if err == errAmbiguousRun {
+			return nil, err
+		}
+		if err != nil {
+			return nil, errors.New("Failed to list runs")
+		}
+		if res.Run == nil {
+			// Show workflows that are still on their first run as running.
+			running, err := sharedRun(ctx, repoClient, query.Owner, query.Repo, query.Branch, query.Run, runInProgress, query.Strict)
+			if err != nil || running == nil {
+				return nil, errors.New("No run found")
+			}
+			res.Run, res.Running = running, true
+			res.Status, res.ResolvedAt = runningText, time.Now()
+			return res, nil
+		}
+		runID := res.Run.GetID()
+		// Get artifacts.
+		artifacts, err := sharedArtifacts(ctx, repoClient, query.Owner, query.Repo, runID)
+		if err != nil {
+			return nil, errors.New("Failed to get artifacts")
+		}
+		// Find artifact matching name.
+		name := "badge_" + query.Badge
+		if query.Strict && countArtifacts(artifacts, name) > 1 {
+			return nil, errAmbiguousArtifact
+		}
+		res.ArtifactID = findArtifactID(artifacts, name)
+		if res.ArtifactID == 0 {
+			return nil, errors.New("Artifact not found in " + strconv.FormatInt(runID, 10))
+		}
+	}
+	text, err := loadArtifact(ctx, repoClient, query.Owner, query.Repo, res.ArtifactID, query.Strict, func(head []byte) bool {
+		if query.Parse != "" {
+			return query.Parse != "plain"
+		}
+		return parse.MayDetect(head)
+	})
+	if err != nil {
+		return nil, errors.New("Failed to download artifact: " + err.Error())
+	}
+	format := query.Parse
+	if format == "" {
+		format = parse.Detect(text)
+	}
+	lines := artifactLines(text)
+	if parser, ok := parse.Lookup(format); ok && format != "plain" {
+		status, err := parser.Parse(text, query.Params)
+		// Detected formats fall back to plain text, which may look like them.
+		switch {
+		case err == nil:
+			lines = []string{truncateBytes(strings.TrimSpace(status), maxLineLen)}
+		case query.Parse != "":
+			return nil, errors.New("Failed to parse artifact: " + err.Error())
+		}
+	}
+	res.Status, res.Empty = emptyText, true
+	if len(lines) > 0 && lines[0] != "" {
+		res.Status, res.Empty = lines[0], false
+	}
+	res.Status, res.Unsafe, err = sanitizeText(redact(res.Status))
+	if err != nil {
+		return nil, err
+	}
+	for i, line := range lines {
+		if i == 0 || line == "" {
+			continue
+		}
+		line, unsafe, err := sanitizeText(redact(line))
+		if err != nil {
+			return nil, err
+		}
+		res.Unsafe = res.Unsafe || unsafe
+		res.Lines = append(res.Lines, line)
+	}
+	res.ResolvedAt = time.Now()
+	return res, nil
+}
+
+// forkParent returns the parent of a forked repo, or nil if it is not a fork.
+// The lookup uses the App installation of the fork if there is one, and is
+// unauthenticated otherwise, since the App is often not installed on forks.
+func forkParent(ctx context.Context, host, owner, repo string) *github.Repository {
+	key := strings.Join([]string{"parent", host, owner, repo}, "\x00")
+	val, err := lookups.do(ctx, key, func(ctx context.Context) (interface{}, error) {
+		client, err := newRepoClient(ctx, host, owner, repo)
+		if err == errNotInstalled {
+			client, err = newHostClient(host, upstreamTransport)
+		}
+		if err != nil {
+			return nil, err
+		}
+		info, _, err := client.Repositories.Get(ctx, owner, repo)
+		if err != nil {
+			return nil, err
+		}
+		return info.GetParent(), nil
+	})
+	if err != nil {
+		return nil
+	}
+	return val.(*github.Repository)
+}
+
+// boolParam reports whether a flag param is set to a true value.
+func boolParam(r *http.Request, key string) bool {
+	on, _ := strconv.ParseBool(r.FormValue(key))
+	return on
+}
+
+// newRepoClient creates a client authenticated as the App installation of a repo.
+// The host is that of a GHES instance, or "" for github.com.
+func newRepoClient(ctx context.Context, host, owner, repo string) (*github.Client, error) {
+	tr, err := appsTransportFor(host)
+	if err != nil {
+		return nil, err
+	}
+	// Get installation ID.
+	appClient, err := newHostClient(host, tr)
+	if err != nil {
+		return nil, err
+	}
+	installation, _, err := appClient.Apps.FindRepositoryInstallation(ctx, owner, repo)
+	if err != nil || installation == nil {
+		return nil, errNotInstalled
+	}
+	return newHostClient(host, ghinstallation.NewFromAppsTransport(tr, installation.GetID()))
+}
+
+// newInstallationClient creates a client authenticated as an App installation.
+// The host is that of a GHES instance, or "" for github.com.
+func newInstallationClient(host string, installationID int64) (*github.Client, error) {
+	tr, err := appsTransportFor(host)
+	if err != nil {
+		return nil, err
+	}
+	return newHostClient(host, ghinstallation.NewFromAppsTransport(tr, installationID))
+}
+
+// sharedRun is findRun shared across concurrent badges of the same run.
+func sharedRun(ctx context.Context, client *github.Client, owner, repo, branch, runName, status string, strict bool) (*github.WorkflowRun, error) {
+	key := strings.Join([]string{"run", client.BaseURL.Host, owner, repo, branch, strings.ToLower(runName), status, strconv.FormatBool(strict)}, "\x00")
+	val, err := lookups.do(ctx, key, func(ctx context.Context) (interface{}, error) {
+		return findRun(ctx, client, owner, repo, branch, runName, status, strict)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return val.(*github.WorkflowRun), nil
+}
+
+// sharedArtifacts lists the artifacts of a run, shared across concurrent badges.
+func sharedArtifacts(ctx context.Context, client *github.Client, owner, repo string, runID int64) ([]*github.Artifact, error) {
+	key := strings.Join([]string{"artifacts", client.BaseURL.Host, owner, repo, strconv.FormatInt(runID, 10)}, "\x00")
+	val, err := lookups.do(ctx, key, func(ctx context.Context) (interface{}, error) {
+		list, _, err := client.Actions.ListWorkflowRunArtifacts(ctx, owner, repo, runID, &github.ListOptions{})
+		if err != nil {
+			return nil, err
+		}
+		return list.Artifacts, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return val.([]*github.Artifact), nil
+}
+
+// findRun returns the latest push run of a workflow with the given status,
+// or nil if there is none. Workflows are resolved by name through the
+// workflow index, falling back to scanning recent runs of the repo.
+// Strict lookups fail with errAmbiguousRun if several workflows share the name.
+func findRun(ctx context.Context, client *github.Client, owner, repo, branch, runName, status string, strict bool) (*github.WorkflowRun, error) {
+	opts := &github.ListWorkflowRunsOptions{
+		Branch: branch,
+		Event:  "push",
+		Status: status,
+	}
+	workflowIDs, err := workflows.lookup(ctx, client, owner, repo, runName)
+	if err != nil {
+		return nil, err
+	}
+	if strict && len(workflowIDs) > 1 {
+		return nil, errAmbiguousRun
+	}
+	if len(workflowIDs) > 0 {
+		// The newest run across all workflows of that name wins.
+		opts.PerPage = 1
+		var found *github.WorkflowRun
+		for _, workflowID := range workflowIDs {
+			runs, _, err := client.Actions.ListWorkflowRunsByID(ctx, owner, repo, workflowID, opts)
+			if err != nil {
+				return nil, err
+			}
+			if len(runs.WorkflowRuns) > 0 && newerRun(runs.WorkflowRuns[0], found) {
+				found = runs.WorkflowRuns[0]
+			}
+		}
+		return found, nil
+	}
+	// List runs in repo.
+	runs, _, err := client.Actions.ListRepositoryWorkflowRuns(ctx, owner, repo, opts)
+	if err != nil {
+		return nil, err
+	}
+	// Find run matching run name.
+	var found *github.WorkflowRun
+	for _, run := range runs.WorkflowRuns {
+		if strings.ToLower(run.GetName()) != strings.ToLower(runName) {
+			continue
+		}
+		if found == nil {
+			found = run
+			if !strict {
+				break
+			}
+		} else if run.GetWorkflowID() != found.GetWorkflowID() {
+			return nil, errAmbiguousRun
+		}
+	}
+	return found, nil
+}
+
+// newerRun reports whether run was created after other, or other is nil.
+func newerRun(run, other *github.WorkflowRun) bool {
+	if other == nil {
+		return true
+	}
+	created, otherCreated := run.GetCreatedAt().Time, other.GetCreatedAt().Time
+	if created.Equal(otherCreated) {
+		return run.GetID() > other.GetID()
+	}
+	return created.After(otherCreated)
+}
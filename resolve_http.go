@@ -0,0 +1,103 @@
+package badge
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// resolveResult is a badge resolved without rendering it.
+type resolveResult struct {
+	Subject    string    `json:"subject,omitempty"`
+	Value      string    `json:"value"`
+	Status     string    `json:"status"`
+	Color      string    `json:"color"`
+	Split      string    `json:"split,omitempty"`
+	RunID      int64     `json:"runId,omitempty"`
+	RunURL     string    `json:"runUrl,omitempty"`
+	ArtifactID int64     `json:"artifactId,omitempty"`
+	Source     string    `json:"source,omitempty"`
+	Private    bool      `json:"private"`
+	Unsafe     bool      `json:"unsafe"`
+	Running    bool      `json:"running"`
+	ResolvedAt time.Time `json:"resolvedAt"`
+}
+
+// ResolveHTTP is a HTTP cloud function that resolves a badge like
+// GenBadgeHTTP and returns the outcome as JSON, without rendering
+// the badge or touching the render cache.
+func ResolveHTTP(w http.ResponseWriter, r *http.Request) {
+	setup()
+	if !requireFeature(w, featureResolve) {
+		return
+	}
+	signed, err := verifySignedLink(r, time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	ctx := r.Context()
+	query, err := parseBadgeQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	query.allowPrivate, _ = requestAccess(r, signed, query.Host, query.Owner)
+	res, err := resolve(ctx, query)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	subject := r.FormValue("subject")
+	if spec := r.FormValue("subjectFrom"); spec != "" {
+		resolved, err := resolveSubject(ctx, query, spec)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if resolved != "" {
+			subject = resolved
+		}
+	}
+	result := resolveResult{
+		Subject:    subject,
+		Value:      res.Status,
+		Status:     res.Status,
+		ArtifactID: res.ArtifactID,
+		Source:     query.Source,
+		Private:    res.Private,
+		Unsafe:     res.Unsafe,
+		Running:    res.Running,
+		ResolvedAt: res.ResolvedAt.UTC(),
+	}
+	var color string
+	result.Status, color, err = displayStatus(r, subject, query, res)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !res.Running {
+		if spec := r.FormValue("split"); spec != "" {
+			result.Split, err = resolveSplit(ctx, query, res, spec)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+	}
+	result.Subject = expandPlaceholders(r, subject, newTemplateData(result.Status, subject, query, res))
+	palette := r.FormValue("palette")
+	if !validPalette(palette) {
+		http.Error(w, "Unknown palette", http.StatusBadRequest)
+		return
+	}
+	result.Color, _ = resolveColors((&Badge{Palette: palette}).palette(), color, defaultStatusColor)
+	if res.Run != nil {
+		result.RunID = res.Run.GetID()
+		result.RunURL = res.Run.GetHTMLURL()
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Robots-Tag", "noindex")
+	w.Header().Set("Content-Type", jsonContentType)
+	json.NewEncoder(w).Encode(&result)
+}
@@ -0,0 +1,98 @@
+package badge
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v37/github"
+)
+
+// newTestClient returns a client of a fake GitHub API served by handler.
+func newTestClient(t *testing.T, handler http.HandlerFunc) *github.Client {
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(srv.URL + "/")
+	return client
+}
+
+func TestFindRunDuplicateWorkflows(t *testing.T) {
+	var listings int32
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/repos/org/dup/actions/workflows":
+			atomic.AddInt32(&listings, 1)
+			fmt.Fprint(w, `{"total_count": 3, "workflows": [
+				{"id": 1, "name": "CI"}, {"id": 2, "name": "CI"}, {"id": 3, "name": "CI"}]}`)
+		case "/repos/org/dup/actions/workflows/1/runs":
+			fmt.Fprint(w, `{"workflow_runs": [{"id": 10, "created_at": "2021-01-02T00:00:00Z"}]}`)
+		case "/repos/org/dup/actions/workflows/2/runs":
+			fmt.Fprint(w, `{"workflow_runs": [{"id": 20, "created_at": "2021-01-03T00:00:00Z"}]}`)
+		case "/repos/org/dup/actions/workflows/3/runs":
+			// The last listed workflow has no matching run.
+			fmt.Fprint(w, `{"workflow_runs": []}`)
+		default:
+			http.NotFound(w, r)
+		}
+	})
+	run, err := findRun(context.Background(), client, "org", "dup", "main", "ci", runSuccess, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if run.GetID() != 20 {
+		t.Errorf("got run %d, want 20", run.GetID())
+	}
+	if _, err := findRun(context.Background(), client, "org", "dup", "main", "CI", runSuccess, true); err != errAmbiguousRun {
+		t.Errorf("strict: got %v, want %v", err, errAmbiguousRun)
+	}
+	if n := atomic.LoadInt32(&listings); n != 1 {
+		t.Errorf("workflow listings: got %d, want 1", n)
+	}
+}
+
+func TestWorkflowIndexSharedMiss(t *testing.T) {
+	var listings int32
+	release := make(chan struct{})
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasSuffix(r.URL.Path, "/actions/workflows") {
+			http.NotFound(w, r)
+			return
+		}
+		atomic.AddInt32(&listings, 1)
+		<-release
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"total_count": 1, "workflows": [{"id": 7, "name": "Build"}]}`)
+	})
+	const callers = 8
+	var wg sync.WaitGroup
+	ids := make([][]int64, callers)
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			ids[i], _ = workflows.lookup(context.Background(), client, "org", "cold", "build")
+		}(i)
+	}
+	for atomic.LoadInt32(&listings) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+	wg.Wait()
+	if n := atomic.LoadInt32(&listings); n != 1 {
+		t.Errorf("workflow listings: got %d, want 1", n)
+	}
+	for i := range ids {
+		if len(ids[i]) != 1 || ids[i][0] != 7 {
+			t.Errorf("caller %d: got %v, want [7]", i, ids[i])
+		}
+	}
+}
@@ -0,0 +1,19 @@
+package badge
+
+import "fmt"
+
+// runningText is the status of badges whose workflow is still running.
+const runningText = "running"
+
+// runningColor is the color of running badges.
+const runningColor = "yellow"
+
+// pulseSize is the diameter of the running indicator, in pixels.
+const pulseSize = 6
+
+// pulseCircle returns an SVG dot fading in and out, left aligned at x.
+func pulseCircle(x, height int) string {
+	return fmt.Sprintf(`<circle cx="%d" cy="%d" r="%d" fill="#fff">`+
+		`<animate attributeName="opacity" values="1;.2;1" dur="1.5s" repeatCount="indefinite"/></circle>`,
+		x+pulseSize/2, height/2, pulseSize/2)
+}
@@ -0,0 +1,60 @@
+package badge
+
+import (
+	"errors"
+	"strings"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Handling of bidi control and invisible characters in resolved values.
+const (
+	unsafeTextAllow  = "allow"
+	unsafeTextFlag   = "flag"
+	unsafeTextStrip  = "strip"
+	unsafeTextReject = "reject"
+)
+
+// unsafeTextMode is the handling in effect.
+var unsafeTextMode = unsafeTextStrip
+
+// errUnsafeText is returned for values rejected by the unsafe text guard.
+var errUnsafeText = errors.New("Status contains bidi control or invisible characters")
+
+// isUnsafeRune reports whether r can make rendered text look different from
+// what it is. The zero width joiner is allowed since emoji sequences use it.
+func isUnsafeRune(r rune) bool {
+	switch {
+	case r >= '\u202a' && r <= '\u202e', // bidi embeddings and overrides
+		r >= '\u2066' && r <= '\u2069',              // bidi isolates
+		r == '\u200e', r == '\u200f', r == '\u061c', // bidi marks
+		r == '\u200b', r == '\u200c', // zero width space and non-joiner
+		r >= '\u2060' && r <= '\u2064',              // word joiner and invisible operators
+		r == '\ufeff', r == '\u00ad', r == '\u180e', // BOM, soft hyphen, vowel separator
+		r == '\u115f', r == '\u1160', r == '\u3164', r == '\uffa0': // Hangul fillers
+		return true
+	}
+	return false
+}
+
+// sanitizeText normalizes a value to NFC and applies the unsafe text guard.
+// It reports whether the value contained unsafe characters.
+func sanitizeText(value string) (string, bool, error) {
+	value = norm.NFC.String(value)
+	if strings.IndexFunc(value, isUnsafeRune) < 0 {
+		return value, false, nil
+	}
+	switch unsafeTextMode {
+	case unsafeTextReject:
+		return "", true, errUnsafeText
+	case unsafeTextStrip:
+		return strings.Map(func(r rune) rune {
+			if isUnsafeRune(r) {
+				return -1
+			}
+			return r
+		}, value), true, nil
+	default:
+		return value, true, nil
+	}
+}
@@ -0,0 +1,66 @@
+package badge
+
+import (
+	"errors"
+	"image"
+	"net/http"
+	"strconv"
+)
+
+// maxScale caps the scale of badges, enough for hero images on project sites.
+const maxScale = 10
+
+// parseScale returns the scale requested by the scale or height param,
+// 1 if neither is set. Heights are relative to the height of the style.
+func parseScale(r *http.Request, style string) (float64, error) {
+	scaleParam, heightParam := r.FormValue("scale"), r.FormValue("height")
+	switch {
+	case scaleParam != "" && heightParam != "":
+		return 0, errors.New("Set either scale or height")
+	case scaleParam != "":
+		scale, err := strconv.ParseFloat(scaleParam, 64)
+		if err != nil || !(scale > 0 && scale <= maxScale) {
+			return 0, errors.New("Invalid scale")
+		}
+		return scale, nil
+	case heightParam != "":
+		height, err := strconv.Atoi(heightParam)
+		scale := float64(height) / float64((&Badge{Style: style}).style().height)
+		if err != nil || !(scale > 0 && scale <= maxScale) {
+			return 0, errors.New("Invalid height")
+		}
+		return scale, nil
+	}
+	return 1, nil
+}
+
+// scale returns the scale of the badge, 1 unless set.
+func (b *Badge) scale() float64 {
+	if b.Scale <= 0 {
+		return 1
+	}
+	return b.Scale
+}
+
+// scaled returns a length multiplied by the scale of the badge, rounded.
+func (b *Badge) scaled(n int) int {
+	return int(float64(n)*b.scale() + 0.5)
+}
+
+// scaleImage resizes an image by nearest neighbor sampling,
+// which keeps the bitmap font crisp.
+func scaleImage(img *image.NRGBA, width, height int) *image.NRGBA {
+	bounds := img.Bounds()
+	if bounds.Dx() == width && bounds.Dy() == height {
+		return img
+	}
+	scaled := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		srcY := y * bounds.Dy() / height
+		for x := 0; x < width; x++ {
+			srcX := x * bounds.Dx() / width
+			copy(scaled.Pix[scaled.PixOffset(x, y):][:4], img.Pix[img.PixOffset(srcX, srcY):][:4])
+		}
+	}
+	return scaled
+}
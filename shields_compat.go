@@ -0,0 +1,87 @@
+package badge
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// artifactURL matches the GitHub page of a workflow run artifact.
+var artifactURL = regexp.MustCompile(`^https://github\.com/([^/]+)/([^/]+)/actions/runs/\d+/artifacts/(\d+)$`)
+
+// errUnsupportedQuery is returned for JSONPath queries beyond plain keys and indices.
+var errUnsupportedQuery = errors.New("unsupported query")
+
+// jsonPathStep matches one step of a JSONPath query: .key, [0] or ['key'].
+var jsonPathStep = regexp.MustCompile(`^(?:\.([^.\[]+)|\[(\d+)\]|\['([^']*)'\]|\["([^"]*)"\])`)
+
+// DynamicJSONHTTP is a HTTP cloud function compatible with the query syntax
+// of shields.io dynamic JSON badges, taking url, query, label, prefix and
+// suffix params. The url must be the GitHub page of an artifact holding a
+// JSON file. Without url, the artifact is selected by the params of
+// GenBadgeHTTP instead. All other params, including prefix and suffix,
+// are those of GenBadgeHTTP.
+func DynamicJSONHTTP(w http.ResponseWriter, r *http.Request) {
+	setup()
+	if !requireFeature(w, featureDynamicJSON) {
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid params", http.StatusBadRequest)
+		return
+	}
+	form := make(url.Values, len(r.Form))
+	for key, values := range r.Form {
+		form[key] = values
+	}
+	if param := form.Get("url"); param != "" {
+		match := artifactURL.FindStringSubmatch(param)
+		if match == nil {
+			http.Error(w, "Unsupported url, expected the URL of an artifact", http.StatusBadRequest)
+			return
+		}
+		form.Set("repo", match[1]+"/"+match[2])
+		form.Set("artifactId", match[3])
+		form.Del("url")
+	}
+	path, err := jsonPathToPath(form.Get("query"))
+	if err != nil {
+		http.Error(w, "Unsupported query", http.StatusBadRequest)
+		return
+	}
+	form.Del("query")
+	form.Set("parse", "json")
+	form.Set("path", path)
+	subject := form.Get("label")
+	if subject == "" {
+		subject = "custom badge"
+	}
+	form.Del("label")
+	form.Set("subject", subject)
+	// Keep the URL, which signatures are checked against.
+	sub := r.Clone(r.Context())
+	sub.Form, sub.PostForm = form, url.Values{}
+	serveBadge(w, sub, "dynamic-json\x00"+r.URL.RawQuery)
+}
+
+// jsonPathToPath turns a JSONPath query like $.a.b[0] into the
+// dot-separated path of the json parser, a.b.0.
+func jsonPathToPath(query string) (string, error) {
+	rest := strings.TrimPrefix(query, "$")
+	var keys []string
+	for rest != "" {
+		match := jsonPathStep.FindStringSubmatch(rest)
+		if match == nil {
+			return "", errUnsupportedQuery
+		}
+		key := match[1] + match[2] + match[3] + match[4]
+		if strings.Contains(key, ".") {
+			return "", errUnsupportedQuery
+		}
+		keys = append(keys, key)
+		rest = rest[len(match[0]):]
+	}
+	return strings.Join(keys, "."), nil
+}
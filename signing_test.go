@@ -0,0 +1,78 @@
+package badge
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestSignedLink(t *testing.T) {
+	defer func(key []byte, cfg *Config) { signingKey, config = key, cfg }(signingKey, config)
+	signingKey = []byte("test key")
+	config = &Config{RevokedLinks: []string{"leaked"}}
+
+	now := time.Unix(1600000000, 0)
+	query := url.Values{"owner": {"octo"}, "repo": {"private"}, "run": {"ci"}}
+	sign := func(expires time.Time, linkID string) url.Values {
+		return SignLink(signingKey, query, expires, linkID)
+	}
+	tamper := func(query url.Values, param, value string) url.Values {
+		query.Set(param, value)
+		return query
+	}
+	tests := []struct {
+		name       string
+		query      url.Values
+		method     string
+		wantSigned bool
+		wantErr    bool
+	}{
+		{"valid", sign(now.Add(time.Hour), ""), "GET", true, false},
+		{"valid with link ID", sign(now.Add(time.Hour), "shared"), "GET", true, false},
+		{"expires now", sign(now, ""), "GET", true, false},
+		{"expired", sign(now.Add(-time.Second), ""), "GET", false, true},
+		{"revoked", sign(now.Add(time.Hour), "leaked"), "GET", false, true},
+		{"tampered repo", tamper(sign(now.Add(time.Hour), ""), "repo", "other"), "GET", false, true},
+		{"tampered expiry", tamper(sign(now.Add(-time.Hour), ""), paramExpires, "9999999999"), "GET", false, true},
+		{"tampered link ID", tamper(sign(now.Add(time.Hour), "leaked"), paramLinkID, "other"), "GET", false, true},
+		{"dropped link ID", tamper(sign(now.Add(time.Hour), "leaked"), paramLinkID, ""), "GET", false, true},
+		{"added param", tamper(sign(now.Add(time.Hour), ""), "branch", "dev"), "GET", false, true},
+		{"wrong key", SignLink([]byte("other key"), query, now.Add(time.Hour), ""), "GET", false, true},
+		{"post", sign(now.Add(time.Hour), ""), "POST", false, true},
+		{"unsigned", query, "GET", false, false},
+		{"sig missing with expires", tamper(url.Values{"owner": {"octo"}, "repo": {"private"}}, paramExpires, "9999999999"), "GET", false, false},
+		{"sig removed", tamper(sign(now.Add(time.Hour), ""), paramSignature, ""), "GET", false, false},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(tt.method, "/?"+tt.query.Encode(), nil)
+		signed, err := verifySignedLink(r, now)
+		if signed != tt.wantSigned || (err != nil) != tt.wantErr {
+			t.Errorf("%s: got %v, %v, want %v, error %v", tt.name, signed, err, tt.wantSigned, tt.wantErr)
+		}
+	}
+}
+
+func TestSignedLinkDisabled(t *testing.T) {
+	defer func(key []byte) { signingKey = key }(signingKey)
+	query := SignLink([]byte("test key"), url.Values{"repo": {"private"}}, time.Now().Add(time.Hour), "")
+	signingKey = nil
+	r := httptest.NewRequest("GET", "/?"+query.Encode(), nil)
+	if signed, err := verifySignedLink(r, time.Now()); signed || err == nil {
+		t.Errorf("got %v, %v, want error", signed, err)
+	}
+}
+
+func TestSignLinkKeepsQuery(t *testing.T) {
+	query := url.Values{"repo": {"private"}, paramSignature: {"stale"}, paramLinkID: {"old"}}
+	signed := SignLink([]byte("test key"), query, time.Unix(1600000000, 0), "")
+	if query.Get(paramSignature) != "stale" || query.Get(paramLinkID) != "old" {
+		t.Error("SignLink modified its input")
+	}
+	if signed.Get(paramLinkID) != "" {
+		t.Errorf("got link ID %q, want none", signed.Get(paramLinkID))
+	}
+	if signed.Get(paramExpires) != "1600000000" {
+		t.Errorf("got expires %q, want 1600000000", signed.Get(paramExpires))
+	}
+}
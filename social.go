@@ -0,0 +1,166 @@
+package badge
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Layout of social preview images, in pixels.
+const (
+	socialWidth  = 1280
+	socialHeight = 640
+	socialMargin = 80
+	// socialBadgeScale is the scale of the badges in the image.
+	socialBadgeScale = 3
+	// socialGap is the space between badges.
+	socialGap = 16
+	// maxTitleScale caps the scale of the title's bitmap font.
+	maxTitleScale = 10
+	// minTitleScale is the scale long titles are cut to fit at.
+	minTitleScale = 4
+	// maxSocialBadges caps the badges of a social preview image.
+	maxSocialBadges = 12
+)
+
+// socialBackground is the background of social preview images.
+var socialBackground = color.NRGBA{0x24, 0x29, 0x2e, 0xff}
+
+// SocialHTTP is a HTTP cloud function that renders a PNG social preview
+// image of a repo, as used by GitHub and link unfurls. It shows the title
+// param, or the repo name, above the badges given like in SpritesHTTP.
+func SocialHTTP(w http.ResponseWriter, r *http.Request) {
+	setup()
+	if !requireFeature(w, featureSocial) {
+		return
+	}
+	signed, err := verifySignedLink(r, time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid params", http.StatusBadRequest)
+		return
+	}
+	title := r.FormValue("title")
+	if title == "" {
+		title = r.FormValue("repo")
+	}
+	specs := r.Form["b"]
+	if title == "" && len(specs) == 0 {
+		http.Error(w, "Missing title key", http.StatusBadRequest)
+		return
+	}
+	if len(specs) > maxSocialBadges {
+		http.Error(w, "Too many badges", http.StatusBadRequest)
+		return
+	}
+	// Resolve badges.
+	ctx := r.Context()
+	badges := make([]*Badge, len(specs))
+	private := make([]bool, len(specs))
+	failed := make([]bool, len(specs))
+	var wg sync.WaitGroup
+	for i, spec := range specs {
+		wg.Add(1)
+		go func(i int, spec string) {
+			defer wg.Done()
+			sub, err := spriteRequest(r, spec)
+			if err == nil {
+				badges[i], private[i], err = spriteBadge(ctx, sub, signed)
+			}
+			if err != nil {
+				badges[i] = &Badge{Subject: "error", Status: err.Error(), Color: "grey"}
+				failed[i] = true
+			}
+			badges[i].Scale = socialBadgeScale
+		}(i, spec)
+	}
+	wg.Wait()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, socialImage(title, badges)); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	cacheControl := fmt.Sprintf("public, max-age=%d", int(renderCacheTTL.Seconds()))
+	for i := range badges {
+		if private[i] {
+			cacheControl = "private, no-cache"
+			break
+		}
+		if failed[i] {
+			cacheControl = "no-cache"
+		}
+	}
+	w.Header().Set("Cache-Control", cacheControl)
+	w.Header().Set("Content-Type", pngContentType)
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	w.Write(buf.Bytes())
+}
+
+// socialImage draws a social preview image with the title above rows of badges.
+func socialImage(title string, badges []*Badge) *image.NRGBA {
+	canvas := image.NewNRGBA(image.Rect(0, 0, socialWidth, socialHeight))
+	fillRect(canvas, canvas.Bounds(), socialBackground)
+	room := socialWidth - 2*socialMargin
+	// Lay out badges in rows.
+	var rows [][]*image.NRGBA
+	var rowWidths []int
+	for _, badge := range badges {
+		img := badge.rasterImage()
+		n := len(rows)
+		if n == 0 || rowWidths[n-1]+socialGap+img.Bounds().Dx() > room {
+			rows = append(rows, nil)
+			rowWidths = append(rowWidths, -socialGap)
+			n++
+		}
+		rows[n-1] = append(rows[n-1], img)
+		rowWidths[n-1] += socialGap + img.Bounds().Dx()
+	}
+	badgesHeight := 0
+	for _, row := range rows {
+		badgesHeight += row[0].Bounds().Dy() + socialGap
+	}
+	// Scale title to fit.
+	var titleImg *image.NRGBA
+	titleHeight := 0
+	if maxLen := (room + 1) / (glyphAdvance * minTitleScale); len([]rune(title)) > maxLen {
+		title = truncateMiddle(title, maxLen)
+	}
+	// Titles of only invisible characters are left out.
+	if textWidth := rasterTextWidth(title); textWidth > 0 {
+		scale := room / textWidth
+		if scale > maxTitleScale {
+			scale = maxTitleScale
+		}
+		text := image.NewNRGBA(image.Rect(0, 0, textWidth, glyphHeight))
+		fillRect(text, text.Bounds(), socialBackground)
+		drawText(text, 0, 0, title, color.NRGBA{0xff, 0xff, 0xff, 0xff})
+		titleImg = scaleImage(text, textWidth*scale, glyphHeight*scale)
+		titleHeight = titleImg.Bounds().Dy() + 2*socialGap
+	}
+	// Center everything vertically.
+	y := (socialHeight - titleHeight - badgesHeight) / 2
+	if titleImg != nil {
+		x := (socialWidth - titleImg.Bounds().Dx()) / 2
+		draw.Draw(canvas, titleImg.Bounds().Add(image.Pt(x, y)), titleImg, image.Point{}, draw.Src)
+		y += titleHeight
+	}
+	for i, row := range rows {
+		x := (socialWidth - rowWidths[i]) / 2
+		for _, img := range row {
+			draw.Draw(canvas, img.Bounds().Add(image.Pt(x, y)), img, image.Point{}, draw.Over)
+			x += img.Bounds().Dx() + socialGap
+		}
+		y += row[0].Bounds().Dy() + socialGap
+	}
+	return canvas
+}
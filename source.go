@@ -0,0 +1,113 @@
+package badge
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// sourceWindow is how long values fetched from external sources are reused.
+const sourceWindow = 5 * time.Minute
+
+// maxSourceResponse caps responses of external sources.
+const maxSourceResponse = 1 << 20
+
+// source resolves a badge value from a service other than GitHub Actions.
+type source func(ctx context.Context, query *badgeQuery) (string, error)
+
+// sources are the external sources selectable with the source param.
+var sources = map[string]source{
+	"codecov":   codecovCoverage,
+	"coveralls": coverallsCoverage,
+	"sonar":     sonarQube,
+	"npm":       npmVersion,
+	"crates":    cratesVersion,
+	"go":        goModuleVersion,
+	"docker":    dockerHub,
+	"probe":     httpProbe,
+}
+
+// sourceLookups shares fetches from external sources.
+var sourceLookups = newCoalescer(sourceWindow)
+
+// resolveSource resolves a query through its external source.
+func resolveSource(ctx context.Context, query *badgeQuery) (*resolution, error) {
+	if !enabledFeatures[featureSources] {
+		return nil, errors.New("External sources are disabled")
+	}
+	fetch, ok := sources[query.Source]
+	if !ok {
+		return nil, errors.New("Unknown source " + query.Source)
+	}
+	status, err := fetch(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	status, unsafe, err := sanitizeText(redact(status))
+	if err != nil {
+		return nil, err
+	}
+	res := &resolution{Status: status, Unsafe: unsafe, ResolvedAt: time.Now()}
+	// Values fetched with credentials may be private.
+	res.Private = query.Source == "sonar" && sonarAuthorized(query)
+	return res, nil
+}
+
+// getJSON fetches and decodes a JSON document, shared through lookups.
+// The decoded value is produced by newDst and must not be modified by callers.
+// Authenticated fetches are only shared with other authenticated ones.
+func getJSON(ctx context.Context, lookups *coalescer, rawURL string, header http.Header, newDst func() interface{}) (interface{}, error) {
+	key := rawURL
+	if header.Get("Authorization") != "" {
+		key = "auth\x00" + rawURL
+	}
+	return lookups.do(ctx, key, func(ctx context.Context) (interface{}, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+		if err != nil {
+			return nil, err
+		}
+		for key, values := range header {
+			req.Header[key] = values
+		}
+		req.Header.Set("Accept", "application/json")
+		res, err := downloadClient.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		defer res.Body.Close()
+		if res.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("status %s", res.Status)
+		}
+		buf := getBuffer()
+		defer putBuffer(buf)
+		if err := readCapped(buf, &contextReader{ctx, res.Body}, maxSourceResponse); err != nil {
+			return nil, err
+		}
+		dst := newDst()
+		if err := json.Unmarshal(buf.Bytes(), dst); err != nil {
+			return nil, err
+		}
+		return dst, nil
+	})
+}
+
+// requireRepo fails queries of sources that need a repo but got none.
+func requireRepo(query *badgeQuery) error {
+	if query.Owner == "" {
+		return errors.New("Missing repo key")
+	}
+	return nil
+}
+
+// pathEscapeAll escapes each path segment.
+func pathEscapeAll(segments ...string) []interface{} {
+	escaped := make([]interface{}, len(segments))
+	for i, segment := range segments {
+		escaped[i] = url.PathEscape(segment)
+	}
+	return escaped
+}
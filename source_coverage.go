@@ -0,0 +1,66 @@
+package badge
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+)
+
+// codecovCoverage fetches the coverage of a repo or branch from Codecov.
+func codecovCoverage(ctx context.Context, query *badgeQuery) (string, error) {
+	if err := requireRepo(query); err != nil {
+		return "", err
+	}
+	type totals struct {
+		Coverage *float64 `json:"coverage"`
+	}
+	type report struct {
+		Totals     totals `json:"totals"`
+		HeadCommit struct {
+			Totals totals `json:"totals"`
+		} `json:"head_commit"`
+	}
+	endpoint := fmt.Sprintf("https://api.codecov.io/api/v2/github/%s/repos/%s/",
+		pathEscapeAll(query.Owner, query.Repo)...)
+	if query.Branch != "" {
+		endpoint += "branches/" + url.PathEscape(query.Branch) + "/"
+	}
+	val, err := getJSON(ctx, sourceLookups, endpoint, nil, func() interface{} { return new(report) })
+	if err != nil {
+		return "", errors.New("Failed to get Codecov report: " + err.Error())
+	}
+	rep := val.(*report)
+	coverage := rep.Totals.Coverage
+	if coverage == nil {
+		coverage = rep.HeadCommit.Totals.Coverage
+	}
+	if coverage == nil {
+		return "", errors.New("No Codecov coverage found")
+	}
+	return formatNumber(*coverage), nil
+}
+
+// coverallsCoverage fetches the coverage of a repo or branch from Coveralls.
+func coverallsCoverage(ctx context.Context, query *badgeQuery) (string, error) {
+	if err := requireRepo(query); err != nil {
+		return "", err
+	}
+	type build struct {
+		CoveredPercent *float64 `json:"covered_percent"`
+	}
+	endpoint := fmt.Sprintf("https://coveralls.io/github/%s/%s.json",
+		pathEscapeAll(query.Owner, query.Repo)...)
+	if query.Branch != "" {
+		endpoint += "?branch=" + url.QueryEscape(query.Branch)
+	}
+	val, err := getJSON(ctx, sourceLookups, endpoint, nil, func() interface{} { return new(build) })
+	if err != nil {
+		return "", errors.New("Failed to get Coveralls build: " + err.Error())
+	}
+	coverage := val.(*build).CoveredPercent
+	if coverage == nil {
+		return "", errors.New("No Coveralls coverage found")
+	}
+	return formatNumber(*coverage), nil
+}
@@ -0,0 +1,67 @@
+package badge
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// dockerHubImage splits the image param into namespace and repository,
+// defaulting to the "library" namespace of official images.
+func dockerHubImage(query *badgeQuery) (string, string, error) {
+	image := query.Params.Get("image")
+	if image == "" {
+		return "", "", errors.New("Missing image key")
+	}
+	parts := strings.SplitN(image, "/", 2)
+	if len(parts) == 1 {
+		return "library", parts[0], nil
+	}
+	return parts[0], parts[1], nil
+}
+
+// dockerHub fetches the pull count of a Docker Hub image,
+// or with metric=tag its most recently pushed tag other than "latest".
+func dockerHub(ctx context.Context, query *badgeQuery) (string, error) {
+	namespace, name, err := dockerHubImage(query)
+	if err != nil {
+		return "", err
+	}
+	endpoint := fmt.Sprintf("https://hub.docker.com/v2/repositories/%s/%s/", pathEscapeAll(namespace, name)...)
+	switch query.Params.Get("metric") {
+	case "", "pulls":
+		type repository struct {
+			PullCount *int64 `json:"pull_count"`
+		}
+		val, err := getJSON(ctx, registryLookups, endpoint, nil, func() interface{} { return new(repository) })
+		if err != nil {
+			return "", errors.New("Failed to get Docker Hub image: " + err.Error())
+		}
+		pulls := val.(*repository).PullCount
+		if pulls == nil {
+			return "", errors.New("No pull count found")
+		}
+		return strconv.FormatInt(*pulls, 10), nil
+	case "tag":
+		type tags struct {
+			Results []struct {
+				Name string `json:"name"`
+			} `json:"results"`
+		}
+		val, err := getJSON(ctx, registryLookups, endpoint+"tags?page_size=25&ordering=last_updated", nil,
+			func() interface{} { return new(tags) })
+		if err != nil {
+			return "", errors.New("Failed to get Docker Hub tags: " + err.Error())
+		}
+		for _, tag := range val.(*tags).Results {
+			if tag.Name != "latest" {
+				return tag.Name, nil
+			}
+		}
+		return "", errors.New("No tag found")
+	default:
+		return "", errors.New("Invalid metric key")
+	}
+}
@@ -0,0 +1,100 @@
+package badge
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// probeWindow is how long probe results are reused.
+const probeWindow = time.Minute
+
+// probeTimeout bounds each probe request.
+const probeTimeout = 10 * time.Second
+
+// probeLookups shares probes of the same URL.
+var probeLookups = newCoalescer(probeWindow)
+
+// probeResult is the outcome of one health check.
+type probeResult struct {
+	up      bool
+	latency time.Duration
+}
+
+// httpProbe checks whether an allowlisted URL responds, reporting
+// "up" with the latency or "down". Use metric=status or metric=latency
+// for either part only.
+func httpProbe(ctx context.Context, query *badgeQuery) (string, error) {
+	target := query.Params.Get("url")
+	if target == "" {
+		return "", errors.New("Missing url key")
+	}
+	if !probeAllowed(target) {
+		return "", errors.New("URL not allowed")
+	}
+	metric := query.Params.Get("metric")
+	switch metric {
+	case "", "status", "latency":
+	default:
+		return "", errors.New("Invalid metric key")
+	}
+	val, err := probeLookups.do(ctx, target, func(ctx context.Context) (interface{}, error) {
+		return probe(ctx, target)
+	})
+	if err != nil {
+		return "", err
+	}
+	res := val.(*probeResult)
+	if !res.up {
+		return "down", nil
+	}
+	latency := strconv.FormatInt(res.latency.Milliseconds(), 10) + "ms"
+	switch metric {
+	case "status":
+		return "up", nil
+	case "latency":
+		return latency, nil
+	default:
+		return "up " + latency, nil
+	}
+}
+
+// probeAllowed reports whether target is on the probe allowlist.
+func probeAllowed(target string) bool {
+	for _, allowed := range config.ProbeURLs {
+		if target == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// probe requests target without following redirects.
+// Failed requests and error statuses count as down rather than as errors
+// so that outages are shared like any other result.
+func probe(ctx context.Context, target string) (*probeResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return nil, err
+	}
+	client := &http.Client{
+		Transport: upstreamTransport,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	start := time.Now()
+	res, err := client.Do(req)
+	if err != nil {
+		return &probeResult{}, nil
+	}
+	res.Body.Close()
+	return &probeResult{
+		up:      res.StatusCode < http.StatusBadRequest,
+		latency: time.Since(start),
+	}, nil
+}
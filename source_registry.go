@@ -0,0 +1,114 @@
+package badge
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// registryWindow is how long package versions are reused.
+// Releases are rare and registries rate limit, so this is long.
+const registryWindow = time.Hour
+
+// registryLookups shares fetches of package versions.
+var registryLookups = newCoalescer(registryWindow)
+
+// registryUserAgent identifies the service to registries that require it.
+const registryUserAgent = "action-badge (https://github.com/terorie/action-badge)"
+
+// packageParam returns the package param of a registry source query.
+func packageParam(query *badgeQuery) (string, error) {
+	name := query.Params.Get("package")
+	if name == "" {
+		return "", errors.New("Missing package key")
+	}
+	return name, nil
+}
+
+// npmVersion fetches the latest version of an npm package.
+func npmVersion(ctx context.Context, query *badgeQuery) (string, error) {
+	name, err := packageParam(query)
+	if err != nil {
+		return "", err
+	}
+	type manifest struct {
+		Version string `json:"version"`
+	}
+	// Scoped packages keep their @ but escape the slash.
+	endpoint := "https://registry.npmjs.org/" + strings.Replace(url.PathEscape(name), "%40", "@", 1) + "/latest"
+	val, err := getJSON(ctx, registryLookups, endpoint, nil, func() interface{} { return new(manifest) })
+	if err != nil {
+		return "", errors.New("Failed to get npm package: " + err.Error())
+	}
+	return versionText(val.(*manifest).Version)
+}
+
+// cratesVersion fetches the latest stable version of a crates.io crate.
+func cratesVersion(ctx context.Context, query *badgeQuery) (string, error) {
+	name, err := packageParam(query)
+	if err != nil {
+		return "", err
+	}
+	type crate struct {
+		Crate struct {
+			MaxStableVersion string `json:"max_stable_version"`
+			MaxVersion       string `json:"max_version"`
+		} `json:"crate"`
+	}
+	header := http.Header{"User-Agent": {registryUserAgent}}
+	val, err := getJSON(ctx, registryLookups, "https://crates.io/api/v1/crates/"+url.PathEscape(name), header,
+		func() interface{} { return new(crate) })
+	if err != nil {
+		return "", errors.New("Failed to get crate: " + err.Error())
+	}
+	info := val.(*crate).Crate
+	if info.MaxStableVersion != "" {
+		return versionText(info.MaxStableVersion)
+	}
+	return versionText(info.MaxVersion)
+}
+
+// goModuleVersion fetches the latest version of a Go module from the module proxy.
+func goModuleVersion(ctx context.Context, query *badgeQuery) (string, error) {
+	name, err := packageParam(query)
+	if err != nil {
+		return "", err
+	}
+	type info struct {
+		Version string `json:"Version"`
+	}
+	val, err := getJSON(ctx, registryLookups, "https://proxy.golang.org/"+escapeModulePath(name)+"/@latest", nil,
+		func() interface{} { return new(info) })
+	if err != nil {
+		return "", errors.New("Failed to get Go module: " + err.Error())
+	}
+	return versionText(val.(*info).Version)
+}
+
+// escapeModulePath applies the module proxy case encoding,
+// replacing each upper case letter with "!" and its lower case.
+func escapeModulePath(path string) string {
+	var b strings.Builder
+	for _, r := range path {
+		if r >= 'A' && r <= 'Z' {
+			b.WriteByte('!')
+			r += 'a' - 'A'
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// versionText formats a version with a leading "v".
+func versionText(version string) (string, error) {
+	if version == "" {
+		return "", errors.New("No version found")
+	}
+	if !strings.HasPrefix(version, "v") {
+		version = "v" + version
+	}
+	return version, nil
+}
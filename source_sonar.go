@@ -0,0 +1,91 @@
+package badge
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/url"
+)
+
+// defaultSonarURL is the SonarQube instance used unless configured otherwise.
+const defaultSonarURL = "https://sonarcloud.io"
+
+// sonarToken authenticates requests to the SonarQube instance, if set.
+var sonarToken []byte
+
+// sonarGateStatuses maps quality gate statuses to displayed text.
+var sonarGateStatuses = map[string]string{
+	"OK":    "passed",
+	"WARN":  "warning",
+	"ERROR": "failed",
+	"NONE":  "none",
+}
+
+// sonarAuthorized reports whether a query may use the SonarQube token.
+// Callers can name any project, so the token is only sent for requests
+// granted private access, and anonymous ones see public projects only.
+func sonarAuthorized(query *badgeQuery) bool {
+	return len(sonarToken) > 0 && query.allowPrivate
+}
+
+// sonarQube fetches the quality gate status, or the metric given by the
+// metric param, of a project. The project param defaults to the
+// SonarCloud key of the repo, "<owner>_<repo>".
+func sonarQube(ctx context.Context, query *badgeQuery) (string, error) {
+	project := query.Params.Get("project")
+	if project == "" {
+		if err := requireRepo(query); err != nil {
+			return "", err
+		}
+		project = query.Owner + "_" + query.Repo
+	}
+	params := url.Values{}
+	if query.Branch != "" {
+		params.Set("branch", query.Branch)
+	}
+	var header http.Header
+	if sonarAuthorized(query) {
+		header = http.Header{"Authorization": {"Basic " + base64.StdEncoding.EncodeToString(append(sonarToken, ':'))}}
+	}
+	metric := query.Params.Get("metric")
+	if metric == "" || metric == "alert_status" {
+		type gate struct {
+			ProjectStatus struct {
+				Status string `json:"status"`
+			} `json:"projectStatus"`
+		}
+		params.Set("projectKey", project)
+		val, err := getJSON(ctx, sourceLookups, config.SonarURL+"/api/qualitygates/project_status?"+params.Encode(), header,
+			func() interface{} { return new(gate) })
+		if err != nil {
+			return "", errors.New("Failed to get quality gate: " + err.Error())
+		}
+		status := val.(*gate).ProjectStatus.Status
+		if text, ok := sonarGateStatuses[status]; ok {
+			return text, nil
+		}
+		return status, nil
+	}
+	type measures struct {
+		Component struct {
+			Measures []struct {
+				Metric string `json:"metric"`
+				Value  string `json:"value"`
+			} `json:"measures"`
+		} `json:"component"`
+	}
+	params.Set("component", project)
+	params.Set("metricKeys", metric)
+	val, err := getJSON(ctx, sourceLookups, config.SonarURL+"/api/measures/component?"+params.Encode(), header,
+		func() interface{} { return new(measures) })
+	if err != nil {
+		return "", errors.New("Failed to get measure: " + err.Error())
+	}
+	for _, measure := range val.(*measures).Component.Measures {
+		if measure.Metric == metric {
+			return measure.Value, nil
+		}
+	}
+	return "", errors.New("No " + metric + " measure found")
+}
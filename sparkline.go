@@ -0,0 +1,75 @@
+package badge
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Sparkline layout, in pixels.
+const (
+	sparkWidth     = 40
+	maxSparkPoints = 20
+)
+
+// sparkValues parses the numeric values of a resolved artifact for a
+// sparkline. The artifact lists values newest first, starting with the
+// status line, and the result is ordered oldest first.
+func sparkValues(res *resolution) ([]float64, error) {
+	lines := append([]string{res.Status}, res.Lines...)
+	var values []float64
+	for _, line := range lines {
+		value, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(line), "%"), 64)
+		// NaN and infinities can't be plotted.
+		if err != nil || math.IsNaN(value) || math.IsInf(value, 0) {
+			continue
+		}
+		values = append(values, value)
+		if len(values) == maxSparkPoints {
+			break
+		}
+	}
+	if len(values) < 2 {
+		return nil, errors.New("Sparkline needs at least two numeric lines")
+	}
+	for i, j := 0, len(values)-1; i < j; i, j = i+1, j-1 {
+		values[i], values[j] = values[j], values[i]
+	}
+	return values, nil
+}
+
+// sparkPoints scales values to points in a box of the given size,
+// with the y axis pointing down.
+func sparkPoints(values []float64, width, height float64) [][2]float64 {
+	lo, hi := values[0], values[0]
+	for _, v := range values {
+		if v < lo {
+			lo = v
+		}
+		if v > hi {
+			hi = v
+		}
+	}
+	points := make([][2]float64, len(values))
+	for i, v := range values {
+		y := height / 2
+		if hi > lo {
+			// Halved so that the span of huge values can't overflow.
+			y = height - (v/2-lo/2)/(hi/2-lo/2)*height
+		}
+		points[i] = [2]float64{float64(i) * width / float64(len(values)-1), y}
+	}
+	return points
+}
+
+// sparkPolyline returns the SVG polyline of a sparkline at x, y.
+func sparkPolyline(values []float64, x, y, height int) string {
+	var coords []string
+	for _, p := range sparkPoints(values, sparkWidth, float64(height)) {
+		coords = append(coords, fmt.Sprintf("%.1f,%.1f", float64(x)+p[0], float64(y)+p[1]))
+	}
+	return `<polyline fill="none" stroke="#fff" stroke-width="1.2" stroke-linejoin="round" points="` +
+		strings.Join(coords, " ") + `"/>`
+}
@@ -0,0 +1,215 @@
+package badge
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// maxSprites caps the badges combined by SpritesHTTP.
+	maxSprites = 50
+	// spriteGap is the space between combined badges, in pixels.
+	spriteGap = 4
+	// defaultSpriteColumns is the column count of grid layouts.
+	defaultSpriteColumns = 4
+)
+
+// Layouts of combined badges, the values of the layout param.
+const (
+	spriteVertical = "vertical"
+	spriteGrid     = "grid"
+)
+
+// SpritesHTTP is a HTTP cloud function that combines several badges into
+// one SVG image. Every b param holds the URL-encoded params of one badge,
+// added to the params of the request itself, so that shared params like
+// repo and branch need to be given once. Badges failing to resolve show
+// their error instead. Logos, split statuses and sparklines are not
+// supported.
+func SpritesHTTP(w http.ResponseWriter, r *http.Request) {
+	setup()
+	if !requireFeature(w, featureSprites) {
+		return
+	}
+	signed, err := verifySignedLink(r, time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid params", http.StatusBadRequest)
+		return
+	}
+	specs := r.Form["b"]
+	if len(specs) == 0 {
+		http.Error(w, "Missing b key", http.StatusBadRequest)
+		return
+	}
+	if len(specs) > maxSprites {
+		http.Error(w, "Too many badges", http.StatusBadRequest)
+		return
+	}
+	columns := 1
+	switch r.FormValue("layout") {
+	case "", spriteVertical:
+	case spriteGrid:
+		columns = defaultSpriteColumns
+		if param := r.FormValue("columns"); param != "" {
+			columns, err = strconv.Atoi(param)
+			if err != nil || columns <= 0 || columns > maxSprites {
+				http.Error(w, "Invalid columns", http.StatusBadRequest)
+				return
+			}
+		}
+	default:
+		http.Error(w, "Unknown layout", http.StatusBadRequest)
+		return
+	}
+	// Resolve badges.
+	ctx := r.Context()
+	badges := make([]*Badge, len(specs))
+	private := make([]bool, len(specs))
+	failed := make([]bool, len(specs))
+	sem := make(chan struct{}, matrixConcurrency)
+	var wg sync.WaitGroup
+	for i, spec := range specs {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, spec string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			sub, err := spriteRequest(r, spec)
+			if err == nil {
+				badges[i], private[i], err = spriteBadge(ctx, sub, signed)
+			}
+			if err != nil {
+				badges[i] = &Badge{Subject: "error", Status: err.Error(), Color: "grey"}
+				failed[i] = true
+			}
+		}(i, spec)
+	}
+	wg.Wait()
+	// Lay out badges in cells of equal width.
+	images := make([]string, len(badges))
+	cellWidth, cellHeight := 0, 0
+	for i, badge := range badges {
+		var width, height int
+		images[i], width, height = badge.svgImage("b" + strconv.Itoa(i) + "-")
+		if width > cellWidth {
+			cellWidth = width
+		}
+		if height > cellHeight {
+			cellHeight = height
+		}
+	}
+	if columns > len(badges) {
+		columns = len(badges)
+	}
+	rows := (len(badges) + columns - 1) / columns
+	width := columns*cellWidth + (columns-1)*spriteGap
+	height := rows*cellHeight + (rows-1)*spriteGap
+	var svg strings.Builder
+	fmt.Fprintf(&svg, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" role="img" aria-label="%d badges">`,
+		width, height, width, height, len(badges))
+	fmt.Fprintf(&svg, `<title>%d badges</title>`, len(badges))
+	for i, image := range images {
+		x := (i % columns) * (cellWidth + spriteGap)
+		y := (i / columns) * (cellHeight + spriteGap)
+		fmt.Fprintf(&svg, `<g transform="translate(%d %d)">%s</g>`, x, y, image)
+	}
+	svg.WriteString(`</svg>`)
+	cacheControl := fmt.Sprintf("public, max-age=%d", int(renderCacheTTL.Seconds()))
+	for i := range badges {
+		if private[i] {
+			cacheControl = "private, no-cache"
+			break
+		}
+		if failed[i] {
+			cacheControl = "no-cache"
+		}
+	}
+	w.Header().Set("Cache-Control", cacheControl)
+	w.Header().Set("Content-Type", svgContentType)
+	w.Header().Set("Content-Length", strconv.Itoa(svg.Len()))
+	w.Write([]byte(svg.String()))
+}
+
+// spriteRequest returns a copy of r with the params of one badge spec added.
+func spriteRequest(r *http.Request, spec string) (*http.Request, error) {
+	params, err := url.ParseQuery(spec)
+	if err != nil {
+		return nil, errors.New("Invalid b key")
+	}
+	form := make(url.Values, len(r.Form)+len(params))
+	for key, values := range r.Form {
+		if key != "b" {
+			form[key] = values
+		}
+	}
+	for key, values := range params {
+		form[key] = values
+	}
+	sub := r.Clone(r.Context())
+	sub.Form, sub.PostForm = form, url.Values{}
+	return sub, nil
+}
+
+// spriteBadge resolves the badge of a request like GenBadgeHTTP.
+// It reports whether the badge must not be cached publicly.
+func spriteBadge(ctx context.Context, r *http.Request, signed bool) (*Badge, bool, error) {
+	style := r.FormValue("style")
+	if !validStyle(style) {
+		return nil, false, errors.New("Unknown style")
+	}
+	query, err := parseBadgeQuery(r)
+	if err != nil {
+		return nil, false, err
+	}
+	var grantedByQuery bool
+	query.allowPrivate, grantedByQuery = requestAccess(r, signed, query.Host, query.Owner)
+	res, err := resolve(ctx, query)
+	if err != nil {
+		return nil, false, err
+	}
+	subject := r.FormValue("subject")
+	if spec := r.FormValue("subjectFrom"); spec != "" {
+		resolved, err := resolveSubject(ctx, query, spec)
+		if err != nil {
+			return nil, false, err
+		}
+		if resolved != "" {
+			subject = resolved
+		}
+	}
+	if subject == "" {
+		return nil, false, errors.New("Missing subject key")
+	}
+	badge := &Badge{
+		Subject: subject,
+		Status:  res.Status,
+		Label:   r.FormValue("label"),
+		List:    r.FormValue("list"),
+		Icon:    r.FormValue("icon"),
+		IconPos: r.FormValue("iconPos"),
+		Icon2:   r.FormValue("icon2"),
+		Style:   style,
+		Palette: r.FormValue("palette"),
+		Alt:     r.FormValue("alt"),
+		Running: res.Running,
+	}
+	badge.Status, badge.Color, err = displayStatus(r, subject, query, res)
+	if err != nil {
+		return nil, false, err
+	}
+	labels := newTemplateData(badge.Status, subject, query, res)
+	badge.Subject = expandPlaceholders(r, badge.Subject, labels)
+	badge.Label = expandPlaceholders(r, badge.Label, labels)
+	return badge, res.Private && !grantedByQuery, nil
+}
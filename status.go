@@ -0,0 +1,188 @@
+package badge
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/v37/github"
+)
+
+// statusCheckTimeout bounds each health check of the status page.
+const statusCheckTimeout = 5 * time.Second
+
+// statusCacheTTL is how long the results of health checks are served.
+const statusCacheTTL = 30 * time.Second
+
+// statusChecks shares health check results, so that requests to the
+// unauthenticated status page can't burn the App rate limit.
+var statusChecks = newCoalescer(statusCacheTTL)
+
+// subsystemStatus is the health of one subsystem.
+type subsystemStatus struct {
+	Name    string `json:"name"`
+	OK      bool   `json:"ok"`
+	Detail  string `json:"detail,omitempty"`
+	Latency string `json:"latency,omitempty"`
+}
+
+// serviceStatus is the summary served by StatusHTTP.
+type serviceStatus struct {
+	OK         bool              `json:"ok"`
+	CheckedAt  time.Time         `json:"checkedAt"`
+	Subsystems []subsystemStatus `json:"subsystems"`
+}
+
+var statusTemplate = template.Must(template.New("status").Parse(`<!DOCTYPE html>
+<html>
+<head><title>action-badge status</title></head>
+<body>
+<h1>action-badge is {{if .OK}}operational{{else}}degraded{{end}}</h1>
+<table>
+<tr><th>Subsystem</th><th>Status</th><th>Latency</th><th>Detail</th></tr>
+{{range .Subsystems}}<tr><td>{{.Name}}</td><td>{{if .OK}}ok{{else}}failing{{end}}</td><td>{{.Latency}}</td><td>{{.Detail}}</td></tr>
+{{end}}</table>
+<p>Checked at {{.CheckedAt.Format "2006-01-02 15:04:05 MST"}}</p>
+</body>
+</html>
+`))
+
+// StatusHTTP is a HTTP cloud function that reports the health of the service.
+// It serves HTML to browsers and JSON otherwise.
+func StatusHTTP(w http.ResponseWriter, r *http.Request) {
+	setup()
+	if !requireFeature(w, featureStatus) {
+		return
+	}
+	val, err := statusChecks.do(r.Context(), "status", func(ctx context.Context) (interface{}, error) {
+		return checkStatus(ctx), nil
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	status := val.(*serviceStatus)
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(statusCacheTTL.Seconds())))
+	code := http.StatusOK
+	if !status.OK {
+		code = http.StatusServiceUnavailable
+	}
+	if wantsHTML(r) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(code)
+		statusTemplate.Execute(w, status)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(status)
+}
+
+// wantsHTML reports whether a request asks for HTML,
+// by format=html or, without a format param, by its Accept header.
+func wantsHTML(r *http.Request) bool {
+	format := r.FormValue("format")
+	return format == "html" || (format == "" && strings.Contains(r.Header.Get("Accept"), "text/html"))
+}
+
+// checkStatus runs all health checks concurrently.
+func checkStatus(ctx context.Context) *serviceStatus {
+	checks := []struct {
+		name  string
+		check func(ctx context.Context) (string, error)
+	}{
+		{"github", checkGitHub},
+		{"renderer", checkRenderer},
+		{"cache", checkCache},
+	}
+	status := &serviceStatus{
+		OK:         true,
+		CheckedAt:  time.Now().UTC(),
+		Subsystems: make([]subsystemStatus, len(checks)),
+	}
+	var wg sync.WaitGroup
+	for i, c := range checks {
+		wg.Add(1)
+		go func(i int, name string, check func(ctx context.Context) (string, error)) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(ctx, statusCheckTimeout)
+			defer cancel()
+			start := time.Now()
+			detail, err := check(ctx)
+			sub := subsystemStatus{
+				Name:    name,
+				OK:      err == nil,
+				Detail:  detail,
+				Latency: time.Since(start).Round(time.Millisecond).String(),
+			}
+			if err != nil {
+				sub.Detail = err.Error()
+			}
+			status.Subsystems[i] = sub
+		}(i, c.name, c.check)
+	}
+	wg.Wait()
+	for _, sub := range status.Subsystems {
+		status.OK = status.OK && sub.OK
+	}
+	return status
+}
+
+// checkGitHub authenticates as the App and reports the remaining rate limit.
+func checkGitHub(ctx context.Context) (string, error) {
+	client := github.NewClient(&http.Client{Transport: appsTransport})
+	app, res, err := client.Apps.Get(ctx, "")
+	if err != nil {
+		return "", err
+	}
+	detail := "app " + app.GetSlug()
+	if res.Rate.Limit > 0 {
+		detail += fmt.Sprintf(", rate limit %d/%d", res.Rate.Remaining, res.Rate.Limit)
+	}
+	return detail, nil
+}
+
+// checkRenderer renders a sample badge, probing the external service
+// of renderers that redirect. For proxied renderers it reports failovers
+// to native rendering instead, which fail no requests.
+func checkRenderer(ctx context.Context) (string, error) {
+	badge := Badge{Subject: "status", Status: "ok", Color: "green"}
+	rendering, err := renderer.Render(ctx, &badge, "")
+	if err != nil {
+		return "", err
+	}
+	if _, proxied := renderer.(proxyRenderer); proxied {
+		return fmt.Sprintf("%s, %d bytes, %s", rendering.ContentType, len(rendering.Body), &proxyHealth), nil
+	}
+	if rendering.Location == "" {
+		if len(rendering.Body) == 0 {
+			return "", errors.New("empty render")
+		}
+		return fmt.Sprintf("%s, %d bytes", rendering.ContentType, len(rendering.Body)), nil
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, rendering.Location, nil)
+	if err != nil {
+		return "", err
+	}
+	res, err := downloadClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("status %s", res.Status)
+	}
+	return req.URL.Host, nil
+}
+
+// checkCache reports the in-memory cache sizes of this instance.
+func checkCache(ctx context.Context) (string, error) {
+	return fmt.Sprintf("in-memory: %d lookups, %d renders, %d workflow indexes",
+		lookups.size(), renders.size(), workflows.size()), nil
+}
@@ -0,0 +1,136 @@
+package badge
+
+import (
+	"errors"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// threshold colors numeric statuses compared to a limit.
+type threshold struct {
+	color string
+	op    string
+	limit float64
+}
+
+// thresholdOps are the comparisons of thresholds, longest first.
+var thresholdOps = []string{"<=", ">=", "<", ">", "="}
+
+// leadingNumber matches the number a status starts with, as in "87.5%".
+var leadingNumber = regexp.MustCompile(`^\s*[+-]?(\d+(\.\d*)?|\.\d+)`)
+
+// parseThresholds parses thresholds like "red:<50,yellow:<80,green:>=80".
+func parseThresholds(spec string) ([]threshold, error) {
+	var thresholds []threshold
+	for _, item := range strings.Split(spec, ",") {
+		parts := strings.SplitN(strings.TrimSpace(item), ":", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, errors.New("expected color:comparison, got " + item)
+		}
+		t := threshold{color: parts[0]}
+		for _, op := range thresholdOps {
+			if strings.HasPrefix(parts[1], op) {
+				t.op = op
+				break
+			}
+		}
+		if t.op == "" {
+			return nil, errors.New("unknown comparison in " + item)
+		}
+		var err error
+		t.limit, err = strconv.ParseFloat(strings.TrimSpace(parts[1][len(t.op):]), 64)
+		if err != nil {
+			return nil, errors.New("invalid limit in " + item)
+		}
+		thresholds = append(thresholds, t)
+	}
+	return thresholds, nil
+}
+
+// match reports whether value passes the threshold.
+func (t *threshold) match(value float64) bool {
+	switch t.op {
+	case "<":
+		return value < t.limit
+	case "<=":
+		return value <= t.limit
+	case ">":
+		return value > t.limit
+	case ">=":
+		return value >= t.limit
+	default:
+		return value == t.limit
+	}
+}
+
+// thresholdColor returns the color of the first threshold matching
+// the number a status starts with, or "" if none does.
+func thresholdColor(thresholds []threshold, status string) string {
+	num := leadingNumber.FindString(status)
+	if num == "" {
+		return ""
+	}
+	value, err := strconv.ParseFloat(strings.TrimSpace(num), 64)
+	if err != nil {
+		return ""
+	}
+	for i := range thresholds {
+		if thresholds[i].match(value) {
+			return thresholds[i].color
+		}
+	}
+	return ""
+}
+
+// colorRule colors statuses matching a regular expression.
+type colorRule struct {
+	pattern *regexp.Regexp
+	color   string
+}
+
+// parseColorRules parses rules like "passing=green,.*beta.*=orange".
+// Patterns must match the whole value.
+func parseColorRules(spec string) ([]colorRule, error) {
+	var rules []colorRule
+	for _, item := range strings.Split(spec, ",") {
+		i := strings.LastIndex(item, "=")
+		if i <= 0 || i == len(item)-1 {
+			return nil, errors.New("expected pattern=color, got " + item)
+		}
+		pattern, err := regexp.Compile("^(?:" + item[:i] + ")$")
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, colorRule{pattern: pattern, color: item[i+1:]})
+	}
+	return rules, nil
+}
+
+// statusColor returns the color of a badge: that of a matching threshold
+// for the formatted status, or else that of a matching color rule for the
+// artifact value, or else the color param.
+func statusColor(r *http.Request, value, status string) (string, error) {
+	if spec := r.FormValue("thresholds"); spec != "" {
+		thresholds, err := parseThresholds(spec)
+		if err != nil {
+			return "", errors.New("Invalid thresholds: " + err.Error())
+		}
+		if color := thresholdColor(thresholds, status); color != "" {
+			return color, nil
+		}
+	}
+	if spec := r.FormValue("colorRules"); spec != "" {
+		rules, err := parseColorRules(spec)
+		if err != nil {
+			return "", errors.New("Invalid colorRules: " + err.Error())
+		}
+		for _, rule := range rules {
+			if rule.pattern.MatchString(value) {
+				return rule.color, nil
+			}
+		}
+	}
+	return r.FormValue("color"), nil
+}
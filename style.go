@@ -0,0 +1,89 @@
+package badge
+
+import "strings"
+
+// badgeStyle describes the look of a native badge.
+type badgeStyle struct {
+	height   int
+	radius   int
+	padding  int
+	fontSize int
+	// baseline is the y coordinate of text.
+	baseline int
+	// gradient is the overlay of the background, none if empty.
+	gradient []gradientStop
+	shadow   bool
+	upper    bool
+	bold     bool
+	// letterSpacing is added after every character, in pixels.
+	letterSpacing float64
+}
+
+type gradientStop struct {
+	offset  string
+	color   string
+	opacity string
+}
+
+// styles are the values of the style param. The empty style is "flat".
+var styles = map[string]*badgeStyle{
+	"flat": {
+		height: 20, radius: 3, padding: 6, fontSize: 11, baseline: 14,
+		gradient: []gradientStop{{"0", "#bbb", ".1"}, {"1", "#000", ".1"}},
+		shadow:   true,
+	},
+	"flat-square": {
+		height: 20, radius: 0, padding: 6, fontSize: 11, baseline: 14,
+	},
+	"plastic": {
+		height: 18, radius: 4, padding: 6, fontSize: 11, baseline: 13,
+		gradient: []gradientStop{{"0", "#fff", ".7"}, {".1", "#aaa", ".1"}, {".9", "#000", ".3"}, {"1", "#000", ".5"}},
+		shadow:   true,
+	},
+	"for-the-badge": {
+		height: 28, radius: 0, padding: 9, fontSize: 10, baseline: 18,
+		upper: true, bold: true, letterSpacing: 1,
+	},
+}
+
+// validStyle reports whether style names a supported badge style.
+func validStyle(style string) bool {
+	_, ok := styles[style]
+	return ok || style == ""
+}
+
+// style returns the style of the badge, "flat" unless set.
+func (b *Badge) style() *badgeStyle {
+	if style, ok := styles[b.Style]; ok {
+		return style
+	}
+	return styles["flat"]
+}
+
+// badgenStyle maps a style onto the closest one supported by badgen.net,
+// which only knows the default gradient look and "flat".
+func badgenStyle(style string) string {
+	switch style {
+	case "flat-square", "for-the-badge":
+		return "flat"
+	}
+	return ""
+}
+
+// styledText applies the case transform of the style.
+func (s *badgeStyle) styledText(text string) string {
+	if s.upper {
+		return strings.ToUpper(text)
+	}
+	return text
+}
+
+// textWidth estimates the rendered width of text in the style, in pixels.
+func (s *badgeStyle) textWidth(text string) int {
+	width := textWidth(text) * float64(s.fontSize) / badgeFontSize
+	if s.bold {
+		width *= 1.1
+	}
+	width += s.letterSpacing * float64(len(visibleRunes(text)))
+	return int(width + 0.5)
+}
@@ -0,0 +1,363 @@
+package badge
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strings"
+)
+
+// badgeFontSize is the font size the character widths are measured at.
+const badgeFontSize = 11
+
+const svgContentType = "image/svg+xml; charset=utf-8"
+
+const (
+	defaultLabelColor  = "#555"
+	defaultStatusColor = "#08C"
+	defaultSplitColor  = "#999"
+	badgeFontFamily    = "Verdana,Geneva,DejaVu Sans,sans-serif"
+)
+
+// namedColors are the color names understood by badgen.net.
+var namedColors = map[string]string{
+	"green":  "#3C1",
+	"blue":   "#08C",
+	"red":    "#E43",
+	"yellow": "#DB1",
+	"orange": "#F73",
+	"purple": "#94E",
+	"pink":   "#E5B",
+	"grey":   "#999",
+	"gray":   "#999",
+	"cyan":   "#1BC",
+	"black":  "#2A2A2A",
+}
+
+// verdanaWidths are the advances of ASCII characters in Verdana at 11px.
+var verdanaWidths = map[rune]float64{
+	' ': 3.87, '!': 4.33, '"': 4.6, '#': 9.16, '$': 6.99, '%': 11.84, '&': 7.96, '\'': 2.69,
+	'(': 4.99, ')': 4.99, '*': 6.99, '+': 9.16, ',': 4.0, '-': 4.99, '.': 4.0, '/': 4.99,
+	'0': 6.99, '1': 6.99, '2': 6.99, '3': 6.99, '4': 6.99, '5': 6.99, '6': 6.99, '7': 6.99,
+	'8': 6.99, '9': 6.99, ':': 4.99, ';': 4.99, '<': 9.16, '=': 9.16, '>': 9.16, '?': 5.94,
+	'@': 10.99, 'A': 7.51, 'B': 7.54, 'C': 7.68, 'D': 8.48, 'E': 6.96, 'F': 6.31, 'G': 8.53,
+	'H': 8.26, 'I': 4.62, 'J': 5.0, 'K': 7.62, 'L': 6.12, 'M': 9.27, 'N': 8.22, 'O': 8.65,
+	'P': 6.64, 'Q': 8.65, 'R': 7.66, 'S': 7.52, 'T': 6.78, 'U': 8.05, 'V': 7.51, 'W': 10.87,
+	'X': 7.53, 'Y': 6.78, 'Z': 7.53, '[': 4.99, '\\': 4.99, ']': 4.99, '^': 9.16, '_': 6.99,
+	'`': 6.99, 'a': 6.66, 'b': 6.83, 'c': 5.72, 'd': 6.83, 'e': 6.57, 'f': 3.77, 'g': 6.83,
+	'h': 6.96, 'i': 3.01, 'j': 3.78, 'k': 6.49, 'l': 3.01, 'm': 10.67, 'n': 6.96, 'o': 6.68,
+	'p': 6.83, 'q': 6.83, 'r': 4.69, 's': 5.73, 't': 4.32, 'u': 6.96, 'v': 6.48, 'w': 8.93,
+	'x': 6.48, 'y': 6.48, 'z': 5.73, '{': 6.98, '|': 4.99, '}': 6.98, '~': 9.16,
+}
+
+// textWidth estimates the rendered width of text in pixels.
+func textWidth(text string) float64 {
+	var width float64
+	for _, r := range visibleRunes(text) {
+		if w, ok := verdanaWidths[r]; ok {
+			width += w
+		} else if isEmojiRune(r) {
+			width += emojiWidth
+		} else if isWideRune(r) {
+			width += badgeFontSize
+		} else {
+			width += 7
+		}
+	}
+	return width
+}
+
+// isWideRune reports whether r is rendered at full width, as CJK characters are.
+func isWideRune(r rune) bool {
+	return r >= 0x1100 && r <= 0x115F ||
+		r >= 0x2E80 && r <= 0xA4CF ||
+		r >= 0xAC00 && r <= 0xD7A3 ||
+		r >= 0xF900 && r <= 0xFAFF ||
+		r >= 0xFE30 && r <= 0xFE4F ||
+		r >= 0xFF00 && r <= 0xFF60 ||
+		r >= 0xFFE0 && r <= 0xFFE6
+}
+
+// colorRangeSep separates the two colors of a gradient fill, as in "blue..purple".
+const colorRangeSep = ".."
+
+// splitColor returns the start and end color of a color param,
+// with an empty end unless it is a gradient.
+func splitColor(color string) (string, string) {
+	if i := strings.Index(color, colorRangeSep); i >= 0 {
+		return color[:i], color[i+len(colorRangeSep):]
+	}
+	return color, ""
+}
+
+// firstColor returns a color param without its gradient end,
+// for renderers that only support solid fills.
+func firstColor(color string) string {
+	from, _ := splitColor(color)
+	return from
+}
+
+// resolveColors resolves the start and end color of a color param.
+// Solid colors end in the start color.
+func resolveColors(p palette, color, def string) (string, string) {
+	from, to := splitColor(color)
+	from = resolveColor(p, from, def)
+	return from, resolveColor(p, to, from)
+}
+
+// resolveColor turns a color name of the palette or a hex code into an SVG color.
+func resolveColor(p palette, color, def string) string {
+	if color == "" {
+		return def
+	}
+	if hex, ok := p[strings.ToLower(color)]; ok {
+		return hex
+	}
+	color = strings.TrimPrefix(color, "#")
+	if isHexColor(color) {
+		return "#" + color
+	}
+	return def
+}
+
+// isHexColor reports whether s is a 3, 4, 6 or 8 digit hex color without "#".
+func isHexColor(s string) bool {
+	switch len(s) {
+	case 3, 4, 6, 8:
+	default:
+		return false
+	}
+	for _, c := range s {
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F') {
+			return false
+		}
+	}
+	return true
+}
+
+// escapeXML escapes text for use in SVG content and attributes.
+func escapeXML(text string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(text))
+	return b.String()
+}
+
+// SVG renders the badge as an SVG image.
+func (b *Badge) SVG() []byte {
+	svg, _, _ := b.svgImage("")
+	return []byte(svg)
+}
+
+// svgImage renders the badge as an svg element and returns its width and height.
+// IDs are prefixed with idPrefix so that several badges can share a document.
+func (b *Badge) svgImage(idPrefix string) (string, int, int) {
+	style := b.style()
+	segments := b.segments()
+	icons := b.segmentIcons(len(segments))
+	if b.IconPos == iconOnly && icons[0] != "" {
+		segments[0].text = ""
+	}
+	// Widths of segments, and of decorations left of their text.
+	widths := make([]int, len(segments))
+	leads := make([]int, len(segments))
+	for i, seg := range segments {
+		if seg.spark != nil {
+			widths[i] = sparkWidth + 2*style.padding
+			continue
+		}
+		if icons[i] != "" {
+			leads[i] += iconSize + iconGap
+		}
+		if seg.pulse {
+			leads[i] += pulseSize + iconGap
+		}
+		if seg.text == "" && leads[i] > 0 {
+			leads[i] -= iconGap
+		}
+		widths[i] = leads[i] + style.textWidth(seg.text) + 2*style.padding
+	}
+	// Status text is squeezed to at most half its width to fit.
+	statusWidth := style.textWidth(segments[1].text)
+	squeeze := b.layoutWidths(widths, statusWidth/2)
+	width := 0
+	for _, w := range widths {
+		width += w
+	}
+	height := style.height
+
+	var svg strings.Builder
+	svg.Grow(1024)
+	alt := escapeXML(b.altText())
+	fmt.Fprintf(&svg, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" role="img" aria-label="%s">`,
+		b.scaled(width), b.scaled(height), width, height, alt)
+	fmt.Fprintf(&svg, `<title>%s</title>`, alt)
+	if len(style.gradient) > 0 {
+		fmt.Fprintf(&svg, `<linearGradient id="%ss" x2="0" y2="100%%">`, idPrefix)
+		for _, stop := range style.gradient {
+			fmt.Fprintf(&svg, `<stop offset="%s" stop-color="%s" stop-opacity="%s"/>`, stop.offset, stop.color, stop.opacity)
+		}
+		svg.WriteString(`</linearGradient>`)
+	}
+	for i, seg := range segments {
+		if seg.colorTo != seg.color {
+			fmt.Fprintf(&svg, `<linearGradient id="%sc%d"><stop offset="0" stop-color="%s"/><stop offset="1" stop-color="%s"/></linearGradient>`,
+				idPrefix, i, seg.color, seg.colorTo)
+		}
+	}
+	fmt.Fprintf(&svg, `<clipPath id="%sr"><rect width="%d" height="%d" rx="%d" fill="#fff"/></clipPath>`,
+		idPrefix, width, height, style.radius)
+	fmt.Fprintf(&svg, `<g clip-path="url(#%sr)">`, idPrefix)
+	x := 0
+	for i, seg := range segments {
+		fill := seg.color
+		if seg.colorTo != seg.color {
+			fill = fmt.Sprintf("url(#%sc%d)", idPrefix, i)
+		}
+		fmt.Fprintf(&svg, `<rect x="%d" width="%d" height="%d" fill="%s"/>`, x, widths[i], height, fill)
+		x += widths[i]
+	}
+	if len(style.gradient) > 0 {
+		fmt.Fprintf(&svg, `<rect width="%d" height="%d" fill="url(#%ss)"/>`, width, height, idPrefix)
+	}
+	svg.WriteString(`</g>`)
+	// Decorations.
+	x = 0
+	for i, seg := range segments {
+		left := x + style.padding
+		if seg.spark != nil {
+			svg.WriteString(sparkPolyline(seg.spark, left, 4, height-8))
+		}
+		if icons[i] != "" {
+			fmt.Fprintf(&svg, `<image x="%d" y="%d" width="%d" height="%d" href="%s"/>`,
+				left, (height-iconSize)/2, iconSize, iconSize, icons[i])
+			left += iconSize + iconGap
+		}
+		if seg.pulse {
+			svg.WriteString(pulseCircle(left, height))
+		}
+		x += widths[i]
+	}
+	fmt.Fprintf(&svg, `<g fill="#fff" text-anchor="middle" font-family="%s" font-size="%d"`,
+		badgeFontFamily, style.fontSize)
+	if style.bold {
+		svg.WriteString(` font-weight="bold"`)
+	}
+	if style.letterSpacing > 0 {
+		fmt.Fprintf(&svg, ` letter-spacing="%g"`, style.letterSpacing)
+	}
+	svg.WriteString(`>`)
+	x = 0
+	for i, seg := range segments {
+		if seg.spark == nil && seg.text != "" {
+			length := 0
+			if i == 1 && squeeze > 0 {
+				length = statusWidth - squeeze
+			}
+			writeText(&svg, style, float64(x+leads[i])+float64(widths[i]-leads[i])/2, seg.text, length)
+		}
+		x += widths[i]
+	}
+	svg.WriteString(`</g></svg>`)
+	return svg.String(), b.scaled(width), b.scaled(height)
+}
+
+// segmentIcons returns the icon data URIs of n segments, "" for those without.
+func (b *Badge) segmentIcons(n int) []string {
+	icons := make([]string, n)
+	icon := b.iconDataURI()
+	if b.IconPos == iconRight {
+		icons[1] = icon
+	} else {
+		icons[0] = icon
+	}
+	if icon2 := namedIconURI(b.Icon2); icon2 != "" {
+		icons[1] = icon2
+	}
+	return icons
+}
+
+// segment is one colored section of a badge.
+type segment struct {
+	text  string
+	color string
+	// colorTo is the end of a left to right gradient, color if solid.
+	colorTo string
+	// spark is drawn instead of text if set.
+	spark []float64
+	// pulse draws an animated dot left of the text.
+	pulse bool
+}
+
+// segments returns the sections of the badge as displayed:
+// the subject, the status and the split status if there is one.
+func (b *Badge) segments() []segment {
+	style := b.style()
+	subject, status := b.texts()
+	statusFrom, statusTo := resolveColors(b.palette(), b.Color, defaultStatusColor)
+	segments := []segment{
+		{text: style.styledText(subject), color: defaultLabelColor, colorTo: defaultLabelColor},
+		{text: style.styledText(status), color: statusFrom, colorTo: statusTo, pulse: b.Running},
+	}
+	if b.Split != "" {
+		split := truncateMiddle(b.Split, b.statusLen())
+		splitFrom, splitTo := resolveColors(b.palette(), b.SplitColor, defaultSplitColor)
+		segments = append(segments, segment{text: style.styledText(split), color: splitFrom, colorTo: splitTo})
+	}
+	if len(b.Sparkline) >= 2 {
+		segments = append(segments, segment{color: defaultLabelColor, colorTo: defaultLabelColor, spark: b.Sparkline})
+	}
+	return segments
+}
+
+// texts returns the subject and status text as displayed.
+func (b *Badge) texts() (string, string) {
+	subject := b.Subject
+	if b.Label != "" {
+		subject = b.Label
+	}
+	status := b.Status
+	if b.List != "" {
+		sep := b.List
+		if sep == "1" || sep == "true" {
+			sep = "|"
+		}
+		items := strings.Split(status, ",")
+		for i := range items {
+			items[i] = strings.TrimSpace(items[i])
+		}
+		status = strings.Join(items, " "+sep+" ")
+	}
+	return truncateMiddle(subject, maxSubjectLen), truncateMiddle(status, b.statusLen())
+}
+
+// flatTexts is texts for renderers without split support,
+// which show the split status after a separator.
+func (b *Badge) flatTexts() (string, string) {
+	subject, status := b.texts()
+	if b.Split != "" {
+		status += " | " + truncateMiddle(b.Split, b.statusLen())
+	}
+	return subject, status
+}
+
+// altText returns the text describing the badge to screen readers.
+func (b *Badge) altText() string {
+	if b.Alt != "" {
+		return b.Alt
+	}
+	subject, status := b.flatTexts()
+	return subject + ": " + status
+}
+
+// writeText writes centered text, with a drop shadow if the style has one.
+// Text is squeezed to length pixels unless it is 0.
+func writeText(svg *strings.Builder, style *badgeStyle, x float64, text string, length int) {
+	escaped := escapeXML(text)
+	var fit string
+	if length > 0 {
+		fit = fmt.Sprintf(` textLength="%d" lengthAdjust="spacingAndGlyphs"`, length)
+	}
+	if style.shadow {
+		fmt.Fprintf(svg, `<text x="%.1f" y="%d" fill="#010101" fill-opacity=".3"%s>%s</text>`, x, style.baseline+1, fit, escaped)
+	}
+	fmt.Fprintf(svg, `<text x="%.1f" y="%d"%s>%s</text>`, x, style.baseline, fit, escaped)
+}
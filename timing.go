@@ -0,0 +1,56 @@
+package badge
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// serverTiming collects the Server-Timing metrics of a badge response.
+type serverTiming struct {
+	start   time.Time
+	metrics []string
+	// render is the time spent rendering, negative until measured.
+	render time.Duration
+}
+
+func newServerTiming() *serverTiming {
+	return &serverTiming{start: time.Now(), render: -1}
+}
+
+// add records a metric with an optional description.
+func (t *serverTiming) add(name, desc string, d time.Duration) {
+	metric := name
+	if desc != "" {
+		metric += ";desc=" + desc
+	}
+	if d >= 0 {
+		metric += fmt.Sprintf(";dur=%s", formatMillis(d))
+	}
+	t.metrics = append(t.metrics, metric)
+}
+
+// renderBadge renders the badge with the configured renderer, timing it.
+func (t *serverTiming) renderBadge(ctx context.Context, badge *Badge, format string) (*Rendering, error) {
+	start := time.Now()
+	rendering, err := renderer.Render(ctx, badge, format)
+	t.render = time.Since(start)
+	t.add("render", "", t.render)
+	return rendering, err
+}
+
+// setHeaders sets the timing headers, including the total time so far.
+func (t *serverTiming) setHeaders(h http.Header) {
+	metrics := append(t.metrics, fmt.Sprintf("total;dur=%s", formatMillis(time.Since(t.start))))
+	h.Set("Server-Timing", strings.Join(metrics, ", "))
+	if t.render >= 0 {
+		h.Set("X-AB-Render-Ms", formatMillis(t.render))
+	}
+}
+
+// formatMillis formats a duration in milliseconds with one decimal.
+func formatMillis(d time.Duration) string {
+	return fmt.Sprintf("%.1f", float64(d)/float64(time.Millisecond))
+}
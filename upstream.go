@@ -0,0 +1,115 @@
+package badge
+
+import (
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// debugUpstream enables logging of upstream requests when non-zero.
+var debugUpstream int32
+
+// setDebugUpstream toggles upstream request logging.
+func setDebugUpstream(on bool) {
+	var v int32
+	if on {
+		v = 1
+	}
+	atomic.StoreInt32(&debugUpstream, v)
+}
+
+// sensitiveHeaders are never written to the upstream log.
+var sensitiveHeaders = map[string]bool{
+	"Authorization": true,
+	"Cookie":        true,
+	"Set-Cookie":    true,
+}
+
+// urlHeaders hold URLs whose query strings are not logged,
+// such as the pre-signed redirect of artifact downloads.
+var urlHeaders = map[string]bool{
+	"Location":         true,
+	"Content-Location": true,
+}
+
+// upstreamTransport is the transport for all GitHub and artifact requests.
+var upstreamTransport http.RoundTripper = &debugTransport{next: http.DefaultTransport}
+
+// configureUpstream sets up the upstream transport chain from the config.
+func configureUpstream(config *Config) {
+	setDebugUpstream(config.DebugUpstream)
+	var tr http.RoundTripper = http.DefaultTransport
+	if config.faultsEnabled() {
+		log.Printf("Injecting upstream faults: error rate %g, latency %s",
+			config.FaultErrorRate, config.FaultLatency)
+		tr = &faultTransport{
+			next:      tr,
+			errorRate: config.FaultErrorRate,
+			latency:   config.FaultLatency,
+		}
+	}
+	upstreamTransport = &debugTransport{next: tr}
+	downloadClient.Transport = upstreamTransport
+}
+
+// downloadClient fetches pre-signed artifact URLs without GitHub auth.
+var downloadClient = &http.Client{Transport: upstreamTransport}
+
+// debugTransport logs requests, responses and rate limits while debugUpstream is set.
+type debugTransport struct {
+	next http.RoundTripper
+}
+
+func (t *debugTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if atomic.LoadInt32(&debugUpstream) == 0 {
+		return t.next.RoundTrip(req)
+	}
+	start := time.Now()
+	res, err := t.next.RoundTrip(req)
+	latency := time.Since(start)
+	target := stripQuery(req.URL.String())
+	if err != nil {
+		log.Printf("upstream %s %s failed after %s: %s req=[%s]",
+			req.Method, target, latency, err, sanitizeHeader(req.Header))
+		return res, err
+	}
+	log.Printf("upstream %s %s status=%d latency=%s ratelimit=%s/%s reset=%s req=[%s] res=[%s]",
+		req.Method, target, res.StatusCode, latency,
+		res.Header.Get("X-RateLimit-Remaining"),
+		res.Header.Get("X-RateLimit-Limit"),
+		res.Header.Get("X-RateLimit-Reset"),
+		sanitizeHeader(req.Header), sanitizeHeader(res.Header))
+	return res, nil
+}
+
+// sanitizeHeader formats a header with sensitive values redacted.
+func sanitizeHeader(header http.Header) string {
+	keys := make([]string, 0, len(header))
+	for key := range header {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	parts := make([]string, 0, len(keys))
+	for _, key := range keys {
+		value := strings.Join(header[key], ",")
+		if sensitiveHeaders[key] {
+			value = redactedValue
+		} else if urlHeaders[key] {
+			value = stripQuery(value)
+		}
+		parts = append(parts, key+": "+value)
+	}
+	return strings.Join(parts, "; ")
+}
+
+// stripQuery drops the query string and fragment of a URL,
+// since those of pre-signed URLs carry credentials.
+func stripQuery(rawURL string) string {
+	if i := strings.IndexAny(rawURL, "?#"); i >= 0 {
+		return rawURL[:i]
+	}
+	return rawURL
+}
@@ -0,0 +1,129 @@
+package badge
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/google/go-github/v37/github"
+)
+
+// Per-repo badge config read by vanity paths.
+const (
+	vanityConfigPath = ".github/badges.json"
+	maxVanityConfig  = 32 << 10
+)
+
+// vanityFormats are the output formats by file extension of vanity paths.
+var vanityFormats = map[string]string{
+	".svg":  "svg",
+	".png":  "png",
+	".webp": "webp",
+	".json": "json",
+}
+
+// VanityHTTP is a HTTP cloud function serving badges from short paths,
+// /:owner/:repo/:name.svg, instead of long query strings. The badge params
+// are read from the named entry of .github/badges.json on the default branch
+// of the repo, which maps badge names to GenBadgeHTTP params:
+//
+//	{"coverage": {"subject": "coverage", "run": "CI", "badge": "coverage"}}
+//
+// Request params override those of the config, except for the repo.
+// Vanity paths can't carry share link signatures.
+func VanityHTTP(w http.ResponseWriter, r *http.Request) {
+	setup()
+	if !requireFeature(w, featureVanity) {
+		return
+	}
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(parts) != 3 {
+		http.Error(w, "Expected /:owner/:repo/:name.svg", http.StatusBadRequest)
+		return
+	}
+	owner, repo, file := parts[0], parts[1], parts[2]
+	ext := path.Ext(file)
+	format, ok := vanityFormats[strings.ToLower(ext)]
+	if !ok {
+		http.Error(w, "Unsupported badge extension", http.StatusBadRequest)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid params", http.StatusBadRequest)
+		return
+	}
+	if r.Form.Get(paramSignature) != "" {
+		http.Error(w, "Vanity paths can't be signed", http.StatusBadRequest)
+		return
+	}
+	allowPrivate, _ := requestAccess(r, false, "", owner)
+	badges, err := loadVanityConfig(r.Context(), owner, repo, allowPrivate)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	params, ok := badges[strings.TrimSuffix(file, ext)]
+	if !ok {
+		http.Error(w, "Unknown badge "+strings.TrimSuffix(file, ext), http.StatusNotFound)
+		return
+	}
+	form := make(url.Values, len(params)+len(r.Form))
+	for key, value := range params {
+		form.Set(key, value)
+	}
+	for key, values := range r.Form {
+		form[key] = values
+	}
+	form.Set("repo", owner+"/"+repo)
+	form.Set("format", format)
+	// The render cache is keyed by the query, which must name the badge.
+	sub := r.Clone(r.Context())
+	sub.URL.RawQuery = form.Encode()
+	sub.Form, sub.PostForm = form, url.Values{}
+	GenBadgeHTTP(w, sub)
+}
+
+// loadVanityConfig returns the badge params by name from the config of a repo.
+func loadVanityConfig(ctx context.Context, owner, repo string, allowPrivate bool) (map[string]map[string]string, error) {
+	client, err := newRepoClient(ctx, "", owner, repo)
+	if err != nil {
+		return nil, err
+	}
+	private, err := isPrivateRepo(ctx, client, owner, repo)
+	if err != nil {
+		return nil, errors.New("Failed to get repo")
+	}
+	if private && !allowPrivate {
+		return nil, errors.New("Repo is private")
+	}
+	key := strings.Join([]string{"vanity", owner, repo}, "\x00")
+	val, err := lookups.do(ctx, key, func(ctx context.Context) (interface{}, error) {
+		file, _, _, err := client.Repositories.GetContents(ctx, owner, repo, vanityConfigPath, &github.RepositoryContentGetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		if file == nil {
+			return nil, errors.New("not a file")
+		}
+		if file.GetSize() > maxVanityConfig {
+			return nil, errors.New("too large")
+		}
+		content, err := file.GetContent()
+		if err != nil {
+			return nil, err
+		}
+		var badges map[string]map[string]string
+		if err := json.Unmarshal([]byte(content), &badges); err != nil {
+			return nil, err
+		}
+		return badges, nil
+	})
+	if err != nil {
+		return nil, errors.New("Failed to get " + vanityConfigPath + ": " + err.Error())
+	}
+	return val.(map[string]map[string]string), nil
+}
@@ -0,0 +1,474 @@
+package badge
+
+import (
+	"encoding/binary"
+	"errors"
+	"image"
+	"math/bits"
+)
+
+const webpContentType = "image/webp"
+
+// WebP renders the badge as a lossless WebP image, drawn as by PNG.
+func (b *Badge) WebP() ([]byte, error) {
+	return encodeWebP(b.rasterImage())
+}
+
+// Limits of the VP8L lossless bitstream.
+const (
+	vp8lMaxSize     = 1 << 14
+	vp8lMaxCodeLen  = 15
+	vp8lMaxCLLen    = 7
+	vp8lMaxCopy     = 4096
+	vp8lMinCopy     = 3
+	vp8lMaxDist     = 1<<20 - 120
+	vp8lMaxCache    = 10
+	vp8lLiterals    = 256
+	vp8lLengthCodes = 24
+	vp8lDistCodes   = 40
+)
+
+// Search limits of backward references.
+const (
+	vp8lHashBits = 14
+	vp8lMaxChain = 32
+)
+
+// vp8lCodeLengthOrder is the order code length code lengths are written in.
+var vp8lCodeLengthOrder = [19]int{17, 18, 0, 1, 2, 3, 4, 5, 16, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15}
+
+// vp8lDistanceMap holds the offsets of the 120 short distance codes,
+// in steps of x and y of the image.
+var vp8lDistanceMap = [120]uint8{
+	0x18, 0x07, 0x17, 0x19, 0x28, 0x06, 0x27, 0x29, 0x16, 0x1a,
+	0x26, 0x2a, 0x38, 0x05, 0x37, 0x39, 0x15, 0x1b, 0x36, 0x3a,
+	0x25, 0x2b, 0x48, 0x04, 0x47, 0x49, 0x14, 0x1c, 0x35, 0x3b,
+	0x46, 0x4a, 0x24, 0x2c, 0x58, 0x45, 0x4b, 0x34, 0x3c, 0x03,
+	0x57, 0x59, 0x13, 0x1d, 0x56, 0x5a, 0x23, 0x2d, 0x44, 0x4c,
+	0x55, 0x5b, 0x33, 0x3d, 0x68, 0x02, 0x67, 0x69, 0x12, 0x1e,
+	0x66, 0x6a, 0x22, 0x2e, 0x54, 0x5c, 0x43, 0x4d, 0x65, 0x6b,
+	0x32, 0x3e, 0x78, 0x01, 0x77, 0x79, 0x53, 0x5d, 0x11, 0x1f,
+	0x64, 0x6c, 0x42, 0x4e, 0x76, 0x7a, 0x21, 0x2f, 0x75, 0x7b,
+	0x31, 0x3f, 0x63, 0x6d, 0x52, 0x5e, 0x00, 0x74, 0x7c, 0x41,
+	0x4f, 0x10, 0x20, 0x62, 0x6e, 0x30, 0x73, 0x7d, 0x51, 0x5f,
+	0x40, 0x72, 0x7e, 0x61, 0x6f, 0x50, 0x71, 0x7f, 0x60, 0x70,
+}
+
+// encodeWebP encodes an image as lossless WebP (VP8L). Of the VP8L tools
+// it uses backward references and the color cache, which suit the few
+// colors and repeated rows of badges, but no transforms.
+func encodeWebP(img *image.NRGBA) ([]byte, error) {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width < 1 || height < 1 || width > vp8lMaxSize || height > vp8lMaxSize {
+		return nil, errors.New("webp: invalid image size")
+	}
+	argb := make([]uint32, 0, width*height)
+	opaque := true
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		row := img.Pix[img.PixOffset(bounds.Min.X, y):]
+		for x := 0; x < width; x++ {
+			p := row[4*x : 4*x+4]
+			argb = append(argb, uint32(p[3])<<24|uint32(p[0])<<16|uint32(p[1])<<8|uint32(p[2]))
+			opaque = opaque && p[3] == 0xff
+		}
+	}
+	tokens := vp8lBackwardRefs(argb, width)
+
+	// Keep the color cache size that encodes smallest.
+	var data []byte
+	for cacheBits := 0; cacheBits <= vp8lMaxCache; cacheBits++ {
+		w := &bitWriter{}
+		w.write(0x2f, 8)
+		w.write(uint32(width-1), 14)
+		w.write(uint32(height-1), 14)
+		if opaque {
+			w.write(0, 1)
+		} else {
+			w.write(1, 1)
+		}
+		w.write(0, 3) // Version.
+		w.write(0, 1) // No transforms.
+		writeVP8LPixels(w, argb, tokens, cacheBits)
+		if encoded := w.bytes(); data == nil || len(encoded) < len(data) {
+			data = encoded
+		}
+	}
+
+	// The RIFF container holds the VP8L chunk, padded to even size.
+	size := len(data) + len(data)&1
+	out := make([]byte, 20+size)
+	copy(out, "RIFF")
+	binary.LittleEndian.PutUint32(out[4:], uint32(12+size))
+	copy(out[8:], "WEBPVP8L")
+	binary.LittleEndian.PutUint32(out[16:], uint32(len(data)))
+	copy(out[20:], data)
+	return out, nil
+}
+
+// vp8lToken is a literal pixel, or a copy of length pixels if length is set.
+type vp8lToken struct {
+	argb   uint32
+	length int
+	// dist is the distance code of copies.
+	dist int
+}
+
+// writeVP8LPixels writes the prefix codes and pixels of an image
+// made of tokens, using a color cache of 1<<cacheBits entries if set.
+func writeVP8LPixels(w *bitWriter, argb []uint32, tokens []vp8lToken, cacheBits int) {
+	var cache []uint32
+	if cacheBits > 0 {
+		cache = make([]uint32, 1<<cacheBits)
+	}
+	// cacheIndex returns the cache entry for color,
+	// which is in use if it holds that color.
+	cacheIndex := func(color uint32) int {
+		return int(color * 0x1e35a7bd >> (32 - cacheBits))
+	}
+	// symbols are the green or cache symbols of tokens.
+	symbols := make([]int, len(tokens))
+	var (
+		green    = make([]int, vp8lLiterals+vp8lLengthCodes+len(cache))
+		red      = make([]int, vp8lLiterals)
+		blue     = make([]int, vp8lLiterals)
+		alpha    = make([]int, vp8lLiterals)
+		distance = make([]int, vp8lDistCodes)
+	)
+	pos := 0
+	for i, t := range tokens {
+		if t.length > 0 {
+			symbol, _, _ := vp8lPrefix(t.length)
+			symbols[i] = vp8lLiterals + symbol
+			symbol, _, _ = vp8lPrefix(t.dist)
+			distance[symbol]++
+		} else if index := cacheIndex(t.argb); cache != nil && cache[index] == t.argb {
+			symbols[i] = vp8lLiterals + vp8lLengthCodes + index
+		} else {
+			symbols[i] = int(t.argb >> 8 & 0xff)
+			red[t.argb>>16&0xff]++
+			blue[t.argb&0xff]++
+			alpha[t.argb>>24]++
+		}
+		green[symbols[i]]++
+		end := pos + 1
+		if t.length > 0 {
+			end = pos + t.length
+		}
+		if cache != nil {
+			for ; pos < end; pos++ {
+				cache[cacheIndex(argb[pos])] = argb[pos]
+			}
+		}
+		pos = end
+	}
+
+	if cache != nil {
+		w.write(1, 1)
+		w.write(uint32(cacheBits), 4)
+	} else {
+		w.write(0, 1)
+	}
+	w.write(0, 1) // A single prefix code group.
+	codes := make([]*prefixCode, 0, 5)
+	for _, counts := range [][]int{green, red, blue, alpha, distance} {
+		code := newPrefixCode(counts, vp8lMaxCodeLen)
+		code.writeTo(w)
+		codes = append(codes, code)
+	}
+	for i, t := range tokens {
+		codes[0].put(w, symbols[i])
+		switch {
+		case t.length > 0:
+			_, extraBits, extra := vp8lPrefix(t.length)
+			w.write(uint32(extra), uint(extraBits))
+			symbol, extraBits, extra := vp8lPrefix(t.dist)
+			codes[4].put(w, symbol)
+			w.write(uint32(extra), uint(extraBits))
+		case symbols[i] < vp8lLiterals:
+			codes[1].put(w, int(t.argb>>16&0xff))
+			codes[2].put(w, int(t.argb&0xff))
+			codes[3].put(w, int(t.argb>>24))
+		}
+	}
+}
+
+// vp8lBackwardRefs greedily replaces repeated runs of pixels by copies.
+// Earlier occurrences are found through a hash chain of pixel pairs,
+// and besides them the pixels to the left and above are tried.
+func vp8lBackwardRefs(argb []uint32, width int) []vp8lToken {
+	// Short codes of distances within a few rows and columns.
+	distCodes := make(map[int]int, len(vp8lDistanceMap))
+	for i := len(vp8lDistanceMap) - 1; i >= 0; i-- {
+		offset := vp8lDistanceMap[i]
+		dist := int(offset>>4)*width + 8 - int(offset&0xf)
+		if dist >= 1 {
+			distCodes[dist] = i + 1
+		}
+	}
+	matchLen := func(i, d int) int {
+		n := 0
+		for n < vp8lMaxCopy && i+n < len(argb) && argb[i+n] == argb[i+n-d] {
+			n++
+		}
+		return n
+	}
+	hash := func(i int) int {
+		if i+1 >= len(argb) {
+			return 0
+		}
+		return int((argb[i]*0x1e35a7bd ^ argb[i+1]*0x9e3779b1) >> (32 - vp8lHashBits))
+	}
+	head := make([]int, 1<<vp8lHashBits)
+	prev := make([]int, len(argb))
+	insert := func(i int) {
+		h := hash(i)
+		prev[i] = head[h]
+		head[h] = i + 1
+	}
+
+	var tokens []vp8lToken
+	for i := 0; i < len(argb); {
+		length, dist := 0, 0
+		for _, d := range [2]int{1, width} {
+			if d <= i {
+				if n := matchLen(i, d); n > length {
+					length, dist = n, d
+				}
+			}
+		}
+		for j, chain := head[hash(i)], 0; j > 0 && chain < vp8lMaxChain && length < vp8lMaxCopy; j, chain = prev[j-1], chain+1 {
+			d := i - (j - 1)
+			if d > vp8lMaxDist {
+				break
+			}
+			if n := matchLen(i, d); n > length {
+				length, dist = n, d
+			}
+		}
+		if length < vp8lMinCopy {
+			tokens = append(tokens, vp8lToken{argb: argb[i]})
+			insert(i)
+			i++
+			continue
+		}
+		code, ok := distCodes[dist]
+		if !ok {
+			code = dist + len(vp8lDistanceMap)
+		}
+		tokens = append(tokens, vp8lToken{length: length, dist: code})
+		for end := i + length; i < end; i++ {
+			insert(i)
+		}
+	}
+	return tokens
+}
+
+// vp8lPrefix splits a copy length or distance code into the prefix symbol
+// and the extra bits following it.
+func vp8lPrefix(v int) (symbol, extraBits, extra int) {
+	v--
+	if v < 4 {
+		return v, 0, 0
+	}
+	high := bits.Len(uint(v)) - 1
+	extraBits = high - 1
+	return 2*high + v>>extraBits&1, extraBits, v & (1<<extraBits - 1)
+}
+
+// prefixCode is a canonical Huffman code.
+type prefixCode struct {
+	lengths []uint8
+	// codes are bit reversed, as they are written least significant bit first.
+	codes []uint16
+	// single is set for codes of one symbol, which take no bits.
+	single bool
+}
+
+// newPrefixCode builds a prefix code for symbols occurring counts times,
+// with codes of at most maxLen bits.
+func newPrefixCode(counts []int, maxLen int) *prefixCode {
+	c := &prefixCode{lengths: huffmanLengths(counts, maxLen), codes: make([]uint16, len(counts))}
+	var perLength [vp8lMaxCodeLen + 1]int
+	used := 0
+	for _, n := range c.lengths {
+		if n > 0 {
+			perLength[n]++
+			used++
+		}
+	}
+	c.single = used == 1
+	var next [vp8lMaxCodeLen + 1]int
+	code := 0
+	for n := 1; n <= vp8lMaxCodeLen; n++ {
+		code = (code + perLength[n-1]) << 1
+		next[n] = code
+	}
+	for symbol, n := range c.lengths {
+		if n > 0 {
+			c.codes[symbol] = uint16(bits.Reverse16(uint16(next[n])) >> (16 - n))
+			next[n]++
+		}
+	}
+	return c
+}
+
+// put writes the code of symbol.
+func (c *prefixCode) put(w *bitWriter, symbol int) {
+	if !c.single {
+		w.write(uint32(c.codes[symbol]), uint(c.lengths[symbol]))
+	}
+}
+
+// writeTo writes the code lengths defining the code.
+func (c *prefixCode) writeTo(w *bitWriter) {
+	last := -1
+	for symbol, n := range c.lengths {
+		if n > 0 {
+			last = symbol
+		}
+	}
+	if last < 0 {
+		// A simple code of symbol 0, which never occurs.
+		w.write(1, 1)
+		w.write(0, 3)
+		return
+	}
+	// Code lengths are themselves prefix coded, with symbols 17 and 18
+	// standing for runs of 3 to 10 and 11 to 138 zeros.
+	type run struct{ symbol, extraBits, extra int }
+	var runs []run
+	for i := 0; i < len(c.lengths); {
+		if c.lengths[i] != 0 {
+			runs = append(runs, run{symbol: int(c.lengths[i])})
+			i++
+			continue
+		}
+		n := 1
+		for n < 138 && i+n < len(c.lengths) && c.lengths[i+n] == 0 {
+			n++
+		}
+		switch {
+		case n >= 11:
+			runs = append(runs, run{18, 7, n - 11})
+		case n >= 3:
+			runs = append(runs, run{17, 3, n - 3})
+		default:
+			n = 1
+			runs = append(runs, run{symbol: 0})
+		}
+		i += n
+	}
+	counts := make([]int, len(vp8lCodeLengthOrder))
+	for _, r := range runs {
+		counts[r.symbol]++
+	}
+	lengthCode := newPrefixCode(counts, vp8lMaxCLLen)
+	written := 4
+	for i, symbol := range vp8lCodeLengthOrder {
+		if lengthCode.lengths[symbol] > 0 && i+1 > written {
+			written = i + 1
+		}
+	}
+	w.write(0, 1) // Not a simple code.
+	w.write(uint32(written-4), 4)
+	for _, symbol := range vp8lCodeLengthOrder[:written] {
+		w.write(uint32(lengthCode.lengths[symbol]), 3)
+	}
+	w.write(0, 1) // Lengths of all symbols follow.
+	for _, r := range runs {
+		lengthCode.put(w, r.symbol)
+		w.write(uint32(r.extra), uint(r.extraBits))
+	}
+}
+
+// huffmanLengths returns the Huffman code lengths of symbols occurring
+// counts times, 0 for those that don't occur. Counts are flattened until
+// no code is longer than maxLen.
+func huffmanLengths(counts []int, maxLen int) []uint8 {
+	lengths := make([]uint8, len(counts))
+	weights := append([]int(nil), counts...)
+	for {
+		// Nodes are the symbols followed by the merged nodes.
+		type node struct{ weight, parent int }
+		nodes := make([]node, 0, 2*len(weights))
+		var active []int
+		for symbol, weight := range weights {
+			nodes = append(nodes, node{weight, -1})
+			if weight > 0 {
+				active = append(active, symbol)
+			}
+		}
+		if len(active) == 0 {
+			return lengths
+		}
+		if len(active) == 1 {
+			lengths[active[0]] = 1
+			return lengths
+		}
+		for len(active) > 1 {
+			// Move the two lightest nodes to the end and merge them.
+			for k := 0; k < 2; k++ {
+				end := len(active) - 1 - k
+				min := 0
+				for i := 1; i <= end; i++ {
+					if nodes[active[i]].weight < nodes[active[min]].weight {
+						min = i
+					}
+				}
+				active[min], active[end] = active[end], active[min]
+			}
+			a, b := active[len(active)-1], active[len(active)-2]
+			parent := len(nodes)
+			nodes = append(nodes, node{nodes[a].weight + nodes[b].weight, -1})
+			nodes[a].parent, nodes[b].parent = parent, parent
+			active = append(active[:len(active)-2], parent)
+		}
+		fits := true
+		for symbol, weight := range weights {
+			depth := 0
+			if weight > 0 {
+				for n := symbol; nodes[n].parent >= 0; n = nodes[n].parent {
+					depth++
+				}
+			}
+			lengths[symbol] = uint8(depth)
+			fits = fits && depth <= maxLen
+		}
+		if fits {
+			return lengths
+		}
+		for symbol, weight := range weights {
+			if weight > 0 {
+				weights[symbol] = (weight + 1) / 2
+			}
+		}
+	}
+}
+
+// bitWriter writes values least significant bit first.
+type bitWriter struct {
+	buf   []byte
+	bits  uint64
+	nBits uint
+}
+
+// write writes the low n bits of v.
+func (w *bitWriter) write(v uint32, n uint) {
+	w.bits |= uint64(v) << w.nBits
+	w.nBits += n
+	for w.nBits >= 8 {
+		w.buf = append(w.buf, byte(w.bits))
+		w.bits >>= 8
+		w.nBits -= 8
+	}
+}
+
+// bytes returns the written bits, padded with zeros to full bytes.
+func (w *bitWriter) bytes() []byte {
+	if w.nBits > 0 {
+		w.buf = append(w.buf, byte(w.bits))
+		w.bits, w.nBits = 0, 0
+	}
+	return w.buf
+}
@@ -0,0 +1,149 @@
+package badge
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"math/rand"
+	"net/http/httptest"
+	"testing"
+
+	"golang.org/x/image/webp"
+)
+
+func TestEncodeWebP(t *testing.T) {
+	images := map[string]*image.NRGBA{
+		"pixel":  image.NewNRGBA(image.Rect(0, 0, 1, 1)),
+		"column": fillImage(image.NewNRGBA(image.Rect(0, 0, 1, 300)), func(x, y int) color.NRGBA { return color.NRGBA{uint8(y), 0, 0, 0xff} }),
+		"flat":   fillImage(image.NewNRGBA(image.Rect(0, 0, 90, 20)), func(x, y int) color.NRGBA { return color.NRGBA{0x4c, 0x1, 0xcc, 0xff} }),
+		"noise": fillImage(image.NewNRGBA(image.Rect(0, 0, 64, 48)), func(x, y int) color.NRGBA {
+			v := rand.Uint32()
+			return color.NRGBA{uint8(v), uint8(v >> 8), uint8(v >> 16), uint8(v >> 24)}
+		}),
+		"long runs": fillImage(image.NewNRGBA(image.Rect(0, 0, 5000, 3)), func(x, y int) color.NRGBA {
+			return color.NRGBA{uint8(x / 4500), 0, uint8(y), 0xff}
+		}),
+		// Fibonacci distributed values need codes longer than 15 bits
+		// unless lengths are limited.
+		"skewed": skewedImage(24),
+		"sub image": fillImage(image.NewNRGBA(image.Rect(0, 0, 40, 30)), func(x, y int) color.NRGBA {
+			return color.NRGBA{uint8(x), uint8(y), uint8(x ^ y), 0x80}
+		}).SubImage(image.Rect(5, 7, 31, 22)).(*image.NRGBA),
+	}
+	badges := []Badge{
+		{Subject: "coverage", Status: "87%", Color: "green"},
+		{Subject: "build", Status: "passing", Color: "#ff0000", Style: "flat-square", Scale: 2},
+		{Subject: "tests", Status: "12 passed", Color: "blue", Style: "plastic"},
+	}
+	for _, b := range badges {
+		images["badge "+b.Subject] = b.rasterImage()
+	}
+	for name, img := range images {
+		data, err := encodeWebP(img)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		decoded, err := webp.Decode(bytes.NewReader(data))
+		if err != nil {
+			t.Errorf("%s: decode: %v", name, err)
+			continue
+		}
+		if !sameImage(img, decoded) {
+			t.Errorf("%s: decoded image differs", name)
+		}
+	}
+}
+
+func TestWebPSmallerThanPNG(t *testing.T) {
+	b := Badge{Subject: "coverage", Status: "87%", Color: "green"}
+	webpData, err := b.WebP()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pngData, err := b.PNG()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(webpData) >= len(pngData) {
+		t.Errorf("got %d bytes of WebP, want less than %d bytes of PNG", len(webpData), len(pngData))
+	}
+}
+
+func TestEncodeWebPSize(t *testing.T) {
+	for _, rect := range []image.Rectangle{image.Rect(0, 0, 0, 1), image.Rect(0, 0, 1<<14+1, 1)} {
+		if _, err := encodeWebP(image.NewNRGBA(rect)); err == nil {
+			t.Errorf("%v: got no error", rect)
+		}
+	}
+}
+
+func TestNegotiateFormat(t *testing.T) {
+	tests := []struct {
+		query    string
+		accept   string
+		want     string
+		wantVary bool
+	}{
+		{"", "image/webp,*/*", "", false},
+		{"format=svg", "image/webp,*/*", "svg", false},
+		{"format=webp", "", "webp", false},
+		{"format=png", "image/png,*/*", "png", true},
+		{"format=png", "image/avif,image/webp,*/*", "webp", true},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", "/?"+tt.query, nil)
+		r.Header.Set("Accept", tt.accept)
+		got := negotiateFormat(w, r)
+		if vary := w.Header().Get("Vary") == "Accept"; got != tt.want || vary != tt.wantVary {
+			t.Errorf("%q with %q: got %q, vary %v, want %q, vary %v", tt.query, tt.accept, got, vary, tt.want, tt.wantVary)
+		}
+	}
+}
+
+func fillImage(img *image.NRGBA, at func(x, y int) color.NRGBA) *image.NRGBA {
+	b := img.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			img.SetNRGBA(x, y, at(x, y))
+		}
+	}
+	return img
+}
+
+// skewedImage returns an image in which the value i of the red channel
+// occurs fib(i) times, shuffled to prevent copies.
+func skewedImage(n int) *image.NRGBA {
+	var values []uint8
+	a, b := 1, 1
+	for i := 0; i < n; i++ {
+		for k := 0; k < a; k++ {
+			values = append(values, uint8(i))
+		}
+		a, b = b, a+b
+	}
+	rand.Shuffle(len(values), func(i, j int) { values[i], values[j] = values[j], values[i] })
+	width := 512
+	img := image.NewNRGBA(image.Rect(0, 0, width, (len(values)+width-1)/width))
+	for i, v := range values {
+		img.SetNRGBA(i%width, i/width, color.NRGBA{v, 0, 0, 0xff})
+	}
+	return img
+}
+
+func sameImage(want *image.NRGBA, got image.Image) bool {
+	b := want.Bounds()
+	if got.Bounds().Dx() != b.Dx() || got.Bounds().Dy() != b.Dy() {
+		return false
+	}
+	offset := got.Bounds().Min.Sub(b.Min)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if color.NRGBAModel.Convert(got.At(x+offset.X, y+offset.Y)) != want.NRGBAAt(x, y) {
+				return false
+			}
+		}
+	}
+	return true
+}
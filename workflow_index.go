@@ -0,0 +1,113 @@
+package badge
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/v37/github"
+)
+
+// workflowIndexTTL is how long a repo's workflow index stays valid.
+const workflowIndexTTL = 5 * time.Minute
+
+// workflowIndexSize caps the number of indexed repos.
+const workflowIndexSize = 1024
+
+// workflowIndex maps lowercase workflow names to workflow IDs per repo.
+// Names are not unique, so each maps to the IDs of all workflows sharing it.
+type workflowIndex struct {
+	mu      sync.Mutex
+	entries map[string]workflowIndexEntry
+}
+
+type workflowIndexEntry struct {
+	ids     map[string][]int64
+	expires time.Time
+}
+
+var workflows = &workflowIndex{entries: make(map[string]workflowIndexEntry)}
+
+// lookup returns the IDs of the workflows with the given name,
+// building the repo's index first if it is missing or expired.
+func (w *workflowIndex) lookup(ctx context.Context, client *github.Client, owner, repo, name string) ([]int64, error) {
+	key := client.BaseURL.Host + "/" + owner + "/" + repo
+	w.mu.Lock()
+	entry, ok := w.entries[key]
+	w.mu.Unlock()
+	if !ok || time.Now().After(entry.expires) {
+		// Concurrent misses of a repo share one listing.
+		val, err := lookups.do(ctx, "workflows\x00"+key, func(ctx context.Context) (interface{}, error) {
+			return listWorkflowIDs(ctx, client, owner, repo)
+		})
+		if err != nil {
+			return nil, err
+		}
+		entry = workflowIndexEntry{ids: val.(map[string][]int64), expires: time.Now().Add(workflowIndexTTL)}
+		w.mu.Lock()
+		if len(w.entries) >= workflowIndexSize {
+			w.purge()
+		}
+		w.entries[key] = entry
+		w.mu.Unlock()
+	}
+	return entry.ids[strings.ToLower(name)], nil
+}
+
+// listWorkflowIDs fetches all workflows of a repo.
+func listWorkflowIDs(ctx context.Context, client *github.Client, owner, repo string) (map[string][]int64, error) {
+	ids := make(map[string][]int64)
+	err := forEachPage(func(opts *github.ListOptions) (*github.Response, error) {
+		list, res, err := client.Actions.ListWorkflows(ctx, owner, repo, opts)
+		if err != nil {
+			return nil, err
+		}
+		for _, workflow := range list.Workflows {
+			name := strings.ToLower(workflow.GetName())
+			ids[name] = append(ids[name], workflow.GetID())
+		}
+		return res, nil
+	})
+	return ids, err
+}
+
+// forEachPage calls list for every page of a paginated API,
+// following the next page parsed from the Link header.
+func forEachPage(list func(opts *github.ListOptions) (*github.Response, error)) error {
+	opts := &github.ListOptions{PerPage: 100}
+	for {
+		res, err := list(opts)
+		if err != nil {
+			return err
+		}
+		if res.NextPage == 0 {
+			return nil
+		}
+		opts.Page = res.NextPage
+	}
+}
+
+// purge drops expired entries, and arbitrary ones if the index stays full.
+// The caller must hold w.mu.
+func (w *workflowIndex) purge() {
+	now := time.Now()
+	for key, entry := range w.entries {
+		if now.After(entry.expires) {
+			delete(w.entries, key)
+		}
+	}
+	for key := range w.entries {
+		if len(w.entries) < workflowIndexSize {
+			break
+		}
+		delete(w.entries, key)
+	}
+}
+
+// size returns the number of indexed repos, expired ones included.
+func (w *workflowIndex) size() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return len(w.entries)
+}